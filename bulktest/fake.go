@@ -0,0 +1,242 @@
+package bulktest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	bulk "github.com/kernle32dll/httpbulk-go"
+)
+
+// FakeResponse is a canned response of a FakeExecutor.
+type FakeResponse struct {
+	// Status is the status code of the response. If not set, 200 is used.
+	Status int
+	Header http.Header
+	Body   string
+
+	// Err, if set, fails the Result with the given error, instead of responding.
+	Err error
+}
+
+type fakeRule struct {
+	pattern  *regexp.Regexp
+	response FakeResponse
+}
+
+// FakeExecutor is an in-memory bulk.Requester, which returns canned responses per
+// url pattern - without issuing any requests. Requests for unmatched urls fail the test.
+type FakeExecutor struct {
+	t testing.TB
+
+	mutex    sync.Mutex
+	rules    []fakeRule
+	requests []*http.Request
+}
+
+var _ bulk.Requester = (*FakeExecutor)(nil)
+
+// NewFakeExecutor instantiates a new FakeExecutor, without any canned responses.
+func NewFakeExecutor(t testing.TB) *FakeExecutor {
+	return &FakeExecutor{t: t}
+}
+
+// On registers the canned response for all urls matching the given pattern, in which
+// "*" matches any sequence of characters (e.g. "https://example.com/users/*"). Patterns
+// are matched against the url after applying the interceptor, and in the order they
+// were registered - so the first matching pattern wins.
+func (f *FakeExecutor) On(pattern string, response FakeResponse) *FakeExecutor {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.rules = append(f.rules, fakeRule{
+		pattern:  regexp.MustCompile("^" + strings.Join(parts, ".*") + "$"),
+		response: response,
+	})
+
+	return f
+}
+
+// Requests returns all requests the FakeExecutor has received, in order.
+func (f *FakeExecutor) Requests() []*http.Request {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]*http.Request(nil), f.requests...)
+}
+
+// AddRequestsWithInterceptor implements bulk.Requester.
+func (f *FakeExecutor) AddRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls ...string,
+) []chan bulk.Result {
+	results := make([]chan bulk.Result, len(urls))
+	for i, url := range urls {
+		results[i] = f.resultChan(ctx, modifyRequest, "", url)
+	}
+
+	return results
+}
+
+// AddRequests implements bulk.Requester.
+func (f *FakeExecutor) AddRequests(ctx context.Context, urls ...string) []chan bulk.Result {
+	return f.AddRequestsWithInterceptor(ctx, nil, urls...)
+}
+
+// AddFutureRequestsWithInterceptor implements bulk.Requester.
+func (f *FakeExecutor) AddFutureRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls ...string,
+) []*bulk.Future {
+	results := make([]*bulk.Future, len(urls))
+	for i, url := range urls {
+		results[i] = bulk.NewFuture(f.resultChan(ctx, modifyRequest, "", url))
+	}
+
+	return results
+}
+
+// AddFutureRequests implements bulk.Requester.
+func (f *FakeExecutor) AddFutureRequests(ctx context.Context, urls ...string) []*bulk.Future {
+	return f.AddFutureRequestsWithInterceptor(ctx, nil, urls...)
+}
+
+// AddKeyedRequestsWithInterceptor implements bulk.Requester.
+func (f *FakeExecutor) AddKeyedRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls map[string]string,
+) map[string]chan bulk.Result {
+	results := make(map[string]chan bulk.Result, len(urls))
+	for key, url := range urls {
+		results[key] = f.resultChan(ctx, modifyRequest, key, url)
+	}
+
+	return results
+}
+
+// AddKeyedRequests implements bulk.Requester.
+func (f *FakeExecutor) AddKeyedRequests(ctx context.Context, urls map[string]string) map[string]chan bulk.Result {
+	return f.AddKeyedRequestsWithInterceptor(ctx, nil, urls)
+}
+
+// AddKeyedFutureRequestsWithInterceptor implements bulk.Requester.
+func (f *FakeExecutor) AddKeyedFutureRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls map[string]string,
+) map[string]*bulk.Future {
+	results := make(map[string]*bulk.Future, len(urls))
+	for key, url := range urls {
+		results[key] = bulk.NewFuture(f.resultChan(ctx, modifyRequest, key, url))
+	}
+
+	return results
+}
+
+// AddKeyedFutureRequests implements bulk.Requester.
+func (f *FakeExecutor) AddKeyedFutureRequests(ctx context.Context, urls map[string]string) map[string]*bulk.Future {
+	return f.AddKeyedFutureRequestsWithInterceptor(ctx, nil, urls)
+}
+
+// resultChan resolves the request synchronously, but delivers it via a buffered
+// channel - just like the bulk.Executor does.
+func (f *FakeExecutor) resultChan(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	key string,
+	url string,
+) chan bulk.Result {
+	resultChannel := make(chan bulk.Result, 1)
+	resultChannel <- f.resolve(ctx, modifyRequest, key, url)
+
+	return resultChannel
+}
+
+func (f *FakeExecutor) resolve(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	key string,
+	url string,
+) bulk.Result {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return bulk.NewResult(key, url, nil, err)
+	}
+
+	if modifyRequest != nil {
+		if err := modifyRequest(req); err != nil {
+			return bulk.NewResult(key, url, nil, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return bulk.NewResult(key, url, nil, err)
+	}
+
+	response, ok := f.match(req)
+	if !ok {
+		err := fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrUnmatchedRequest)
+		if f.t != nil {
+			f.t.Errorf("bulktest: %s", err)
+		}
+		return bulk.NewResult(key, url, nil, err)
+	}
+
+	if response.Err != nil {
+		return bulk.NewResult(key, url, nil, response.Err)
+	}
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// each request gets its own copy, so consumers may modify it freely
+	header := response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return bulk.NewResult(key, url, &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(response.Body))),
+		ContentLength: int64(len(response.Body)),
+		Request:       req,
+	}, nil)
+}
+
+// match records the request, and returns the response of the first matching rule.
+func (f *FakeExecutor) match(req *http.Request) (FakeResponse, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.requests = append(f.requests, req)
+
+	url := req.URL.String()
+	for _, rule := range f.rules {
+		if rule.pattern.MatchString(url) {
+			return rule.response, true
+		}
+	}
+
+	return FakeResponse{}, false
+}
@@ -0,0 +1,217 @@
+package bulktest_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+	"github.com/kernle32dll/httpbulk-go/bulktest"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the fake executor serves canned responses per url pattern, with the first matching pattern winning.
+func Test_FakeExecutor_CannedResponses(t *testing.T) {
+	// given
+	failure := errors.New("connection refused")
+	fake := bulktest.NewFakeExecutor(t).
+		On("https://example.com/users/admin", bulktest.FakeResponse{Status: http.StatusForbidden}).
+		On("https://example.com/users/*", bulktest.FakeResponse{Body: "user"}).
+		On("https://down.example.com/*", bulktest.FakeResponse{Err: failure})
+
+	// when
+	results := fake.AddRequests(context.Background(),
+		"https://example.com/users/admin",
+		"https://example.com/users/42",
+		"https://down.example.com/anything",
+	)
+	futures := fake.AddKeyedFutureRequests(context.Background(), map[string]string{
+		"someone": "https://example.com/users/7",
+	})
+
+	// then
+	if actual := readResult(t, <-results[0]); actual != "403 " {
+		t.Errorf("expected forbidden response, got %q", actual)
+	}
+
+	if actual := readResult(t, <-results[1]); actual != "200 user" {
+		t.Errorf("expected user response, got %q", actual)
+	}
+
+	if err := (<-results[2]).Err(); !errors.Is(err, failure) {
+		t.Errorf("expected canned error, got %v", err)
+	}
+
+	result := futures["someone"].Get()
+	if result.Key() != "someone" || readResult(t, result) != "200 user" {
+		t.Errorf("unexpected keyed result %q", result.Key())
+	}
+
+	if requests := fake.Requests(); len(requests) != 4 {
+		t.Errorf("expected 4 requests, got %d", len(requests))
+	}
+}
+
+// Tests that the fake executor can be passed to helpers, and observes their interceptors.
+func Test_FakeExecutor_Helpers(t *testing.T) {
+	// given
+	lastModified := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	fake := bulktest.NewFakeExecutor(t).
+		On("https://example.com/*?token=secret", bulktest.FakeResponse{
+			Header: http.Header{"Last-Modified": []string{lastModified.Format(http.TimeFormat)}},
+		})
+
+	// when
+	times, err := bulk.FetchLastModDatesForURLs(context.Background(), fake, func(r *http.Request) error {
+		r.URL.RawQuery = "token=secret"
+		return nil
+	}, "https://example.com/a", "https://example.com/b")
+
+	// then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	for _, actual := range times {
+		if !actual.Equal(lastModified) {
+			t.Errorf("expected %s, got %s", lastModified, actual)
+		}
+	}
+
+	for _, req := range fake.Requests() {
+		if req.Method != http.MethodHead {
+			t.Errorf("expected HEAD request, got %s", req.Method)
+		}
+	}
+}
+
+// Tests that the fake executor can back a Loader, which batches keys into a single request.
+func Test_FakeExecutor_Loader(t *testing.T) {
+	// given
+	fake := bulktest.NewFakeExecutor(t).
+		On("https://example.com/items?ids=*", bulktest.FakeResponse{Body: `{"a":1,"b":2}`})
+
+	loader := bulk.NewLoader(context.Background(), fake,
+		func(keys []string) (string, error) {
+			return "https://example.com/items?ids=" + strings.Join(keys, ","), nil
+		},
+		func(keys []string, body []byte) (map[string][]byte, error) {
+			var items map[string]json.RawMessage
+			err := json.Unmarshal(body, &items)
+
+			split := make(map[string][]byte, len(items))
+			for key, item := range items {
+				split[key] = item
+			}
+			return split, err
+		},
+	)
+
+	// when
+	futures := loader.LoadMany("a", "b")
+	loader.Flush()
+
+	// then
+	for i, expected := range []int{1, 2} {
+		var actual int
+		if err := futures[i].UnmarshalResponse(&actual); err != nil || actual != expected {
+			t.Errorf("expected %d, got %d and %v", expected, actual, err)
+		}
+	}
+
+	if requests := fake.Requests(); len(requests) != 1 {
+		t.Errorf("expected a single batch request, got %d", len(requests))
+	}
+}
+
+// Tests that the fake executor can drive pagination and dependency graphs.
+func Test_FakeExecutor_PaginateAndGraph(t *testing.T) {
+	// given
+	fake := bulktest.NewFakeExecutor(t).
+		On("https://example.com/items?page=2", bulktest.FakeResponse{Body: `{"last":true}`}).
+		On("https://example.com/items?page=*", bulktest.FakeResponse{Body: `{"last":false}`}).
+		On("https://example.com/users/1", bulktest.FakeResponse{Body: `{"org":"acme"}`}).
+		On("https://example.com/orgs/acme", bulktest.FakeResponse{Body: `{"name":"Acme"}`})
+
+	strategy := bulk.OffsetStrategy{
+		Param: "page",
+		Step:  1,
+		IsLast: func(page bulk.Page) (bool, error) {
+			var body struct {
+				Last bool `json:"last"`
+			}
+			err := page.UnmarshalResponse(&body)
+			return body.Last, err
+		},
+	}
+
+	// when
+	var pages int
+	for page := range bulk.Paginate(context.Background(), fake, "https://example.com/items?page=0", strategy) {
+		if page.Err() != nil {
+			t.Fatalf("unexpected error %s", page.Err())
+		}
+		pages++
+	}
+
+	futures, err := bulk.ExecuteGraph(context.Background(), fake,
+		bulk.Node{
+			Name: "user",
+			Build: func(map[string]*bulk.Future) (string, error) {
+				return "https://example.com/users/1", nil
+			},
+		},
+		bulk.Node{
+			Name:      "org",
+			DependsOn: []string{"user"},
+			Build: func(upstream map[string]*bulk.Future) (string, error) {
+				var user struct {
+					Org string `json:"org"`
+				}
+				err := upstream["user"].UnmarshalResponse(&user)
+				return "https://example.com/orgs/" + user.Org, err
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	if pages != 3 {
+		t.Errorf("expected 3 pages, got %d", pages)
+	}
+
+	var org struct {
+		Name string `json:"name"`
+	}
+	if err := futures["org"].UnmarshalResponse(&org); err != nil || org.Name != "Acme" {
+		t.Errorf("expected org to be built from user, got %+v and %v", org, err)
+	}
+
+	if key := futures["org"].Get().Key(); key != "org" {
+		t.Errorf("expected result keyed by node name, got %q", key)
+	}
+}
+
+// Tests that requests for unmatched urls fail the test, and the result.
+func Test_FakeExecutor_Unmatched(t *testing.T) {
+	// given
+	tb := &recordingTB{TB: t}
+	fake := bulktest.NewFakeExecutor(tb)
+
+	// when
+	result := <-fake.AddRequests(context.Background(), "https://example.com/unknown")[0]
+
+	// then
+	if !errors.Is(result.Err(), bulktest.ErrUnmatchedRequest) {
+		t.Errorf("expected unmatched error, got %v", result.Err())
+	}
+
+	if len(tb.errors) != 1 {
+		t.Errorf("expected the test to fail once, got %v", tb.errors)
+	}
+}
@@ -0,0 +1,276 @@
+package bulktest
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Latency returns the delay of a single response.
+type Latency func() time.Duration
+
+// FixedLatency always delays responses by the given duration.
+func FixedLatency(d time.Duration) Latency {
+	return func() time.Duration {
+		return d
+	}
+}
+
+// UniformLatency delays responses by a uniformly distributed duration between min and max.
+// The distribution is seeded deterministically, so repeated test runs see the same sequence.
+func UniformLatency(min, max time.Duration) Latency {
+	var mutex sync.Mutex
+	random := rand.New(rand.NewSource(1))
+
+	return func() time.Duration {
+		if max <= min {
+			return min
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return min + time.Duration(random.Int63n(int64(max-min)))
+	}
+}
+
+// Response is a single scripted response of a Route.
+type Response struct {
+	// Status is the status code of the response. If not set, 200 is used.
+	Status int
+	Header http.Header
+	Body   string
+
+	// Latency delays the response (before writing any headers), if set.
+	Latency Latency
+
+	// Reset resets the connection, instead of responding.
+	Reset bool
+
+	// PartialBytes, if set, only writes the given number of bytes of the body, before
+	// aborting the connection - while the Content-Length of the full body is announced.
+	PartialBytes int
+
+	// ChunkSize and ChunkDelay, if both set, write the body in chunks of the given size,
+	// delaying each chunk - simulating a slow body.
+	ChunkSize  int
+	ChunkDelay time.Duration
+}
+
+// Route is a path of a Server, which serves scripted responses.
+type Route struct {
+	mutex     sync.Mutex
+	responses []Response
+	hits      int
+}
+
+// Hits returns the number of requests the route has received.
+func (r *Route) Hits() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.hits
+}
+
+// next returns the next scripted response. The last one is repeated as required.
+func (r *Route) next() Response {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.hits++
+	if r.hits > len(r.responses) {
+		return r.responses[len(r.responses)-1]
+	}
+
+	return r.responses[r.hits-1]
+}
+
+// Server is a fake server based on httptest.Server, serving scripted responses per route.
+// It keeps track of the number of concurrent requests, for asserting concurrency limits.
+// Requests for unknown paths fail the test.
+type Server struct {
+	*httptest.Server
+
+	t testing.TB
+
+	mutex         sync.Mutex
+	routes        map[string]*Route
+	requests      int
+	concurrent    int
+	maxConcurrent int
+}
+
+// NewServer starts a new Server, which is closed once the test finished.
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		t:      t,
+		routes: map[string]*Route{},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+// Handle scripts the responses for the given path (regardless of the method), served in
+// order. Once all responses were served, the last one is repeated. Without any responses,
+// an empty 200 response is served.
+func (s *Server) Handle(path string, responses ...Response) *Route {
+	if len(responses) == 0 {
+		responses = []Response{{}}
+	}
+
+	route := &Route{responses: responses}
+
+	s.mutex.Lock()
+	s.routes[path] = route
+	s.mutex.Unlock()
+
+	return route
+}
+
+// Requests returns the number of requests the server has received.
+func (s *Server) Requests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.requests
+}
+
+// MaxConcurrent returns the highest number of requests, which were handled at the same time.
+func (s *Server) MaxConcurrent() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.maxConcurrent
+}
+
+// AssertMaxConcurrent fails the test, if more than max requests were handled at the same time.
+func (s *Server) AssertMaxConcurrent(max int) {
+	s.t.Helper()
+
+	if concurrent := s.MaxConcurrent(); concurrent > max {
+		s.t.Errorf("bulktest: expected at most %d concurrent requests, got %d", max, concurrent)
+	}
+}
+
+// AssertRequests fails the test, if the server did not receive exactly the given number of requests.
+func (s *Server) AssertRequests(requests int) {
+	s.t.Helper()
+
+	if actual := s.Requests(); actual != requests {
+		s.t.Errorf("bulktest: expected %d requests, got %d", requests, actual)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	route := s.routes[r.URL.Path]
+	s.requests++
+	s.concurrent++
+	if s.concurrent > s.maxConcurrent {
+		s.maxConcurrent = s.concurrent
+	}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		s.concurrent--
+		s.mutex.Unlock()
+	}()
+
+	if route == nil {
+		s.t.Errorf("bulktest: unexpected request %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	respond(w, r, route.next())
+}
+
+func respond(w http.ResponseWriter, r *http.Request, response Response) {
+	if response.Latency != nil {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(response.Latency()):
+		}
+	}
+
+	if response.Reset {
+		reset(w)
+		return
+	}
+
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	body := []byte(response.Body)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	if response.PartialBytes > 0 && response.PartialBytes < len(body) {
+		body = body[:response.PartialBytes]
+	}
+
+	w.WriteHeader(status)
+
+	chunkSize := len(body)
+	if response.ChunkSize > 0 && response.ChunkDelay > 0 {
+		chunkSize = response.ChunkSize
+	}
+
+	for start := 0; start < len(body); start += chunkSize {
+		if start > 0 {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(response.ChunkDelay):
+			}
+		}
+
+		end := start + chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+
+		w.Write(body[start:end])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+	}
+
+	if response.PartialBytes > 0 && response.PartialBytes < len(response.Body) {
+		// aborts the connection, without the server logging a stack trace
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// reset closes the underlying connection, with a TCP reset if possible.
+func reset(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,123 @@
+package bulktest_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+	"github.com/kernle32dll/httpbulk-go/bulktest"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Tests that scripted responses are served in order, and the concurrency limit of the executor holds.
+func Test_Server_ScriptedResponses(t *testing.T) {
+	// given
+	server := bulktest.NewServer(t)
+	route := server.Handle("/items",
+		bulktest.Response{Status: http.StatusServiceUnavailable},
+		bulktest.Response{Body: "ok", Latency: bulktest.UniformLatency(10*time.Millisecond, 20*time.Millisecond)},
+	)
+
+	executor := bulk.NewExecutor(bulk.ConcurrencyLimit(3))
+	defer executor.Close()
+
+	urls := make([]string, 10)
+	for i := range urls {
+		urls[i] = server.URL + "/items"
+	}
+
+	// when
+	first := <-executor.AddRequests(context.Background(), server.URL+"/items")[0]
+	results := executor.AddRequests(context.Background(), urls...)
+
+	// then
+	if first.Err() != nil || first.Res().StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected first response to be unavailable, got %v", first.Err())
+	}
+
+	for _, resultChan := range results {
+		result := <-resultChan
+		if result.Err() != nil {
+			t.Fatalf("unexpected error %s", result.Err())
+		}
+
+		body, _ := ioutil.ReadAll(result.Res().Body)
+		result.Res().Body.Close()
+		if result.Res().StatusCode != http.StatusOK || string(body) != "ok" {
+			t.Errorf("unexpected response %d %q", result.Res().StatusCode, body)
+		}
+	}
+
+	if route.Hits() != 11 {
+		t.Errorf("expected 11 hits, got %d", route.Hits())
+	}
+
+	server.AssertRequests(11)
+	server.AssertMaxConcurrent(3)
+}
+
+// Tests that connection resets, partial writes and slow bodies are injected.
+func Test_Server_Faults(t *testing.T) {
+	// given
+	server := bulktest.NewServer(t)
+	server.Handle("/reset", bulktest.Response{Reset: true})
+	server.Handle("/partial", bulktest.Response{Body: "0123456789", PartialBytes: 4})
+	server.Handle("/slow", bulktest.Response{Body: "0123456789", ChunkSize: 2, ChunkDelay: 10 * time.Millisecond})
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	results := executor.AddRequests(context.Background(), server.URL+"/reset", server.URL+"/partial", server.URL+"/slow")
+
+	// then
+	if err := (<-results[0]).Err(); err == nil {
+		t.Error("expected error for reset connection")
+	}
+
+	partial := <-results[1]
+	if partial.Err() != nil {
+		t.Fatalf("unexpected error %s", partial.Err())
+	}
+
+	body, err := ioutil.ReadAll(partial.Res().Body)
+	partial.Res().Body.Close()
+	if err == nil || string(body) != "0123" {
+		t.Errorf("expected partial body with error, got %q (%v)", body, err)
+	}
+
+	slow := <-results[2]
+	if slow.Err() != nil {
+		t.Fatalf("unexpected error %s", slow.Err())
+	}
+
+	start := time.Now()
+	body, err = ioutil.ReadAll(slow.Res().Body)
+	slow.Res().Body.Close()
+	if err != nil || string(body) != "0123456789" || time.Since(start) < 30*time.Millisecond {
+		t.Errorf("expected slow body, got %q (%v) after %s", body, err, time.Since(start))
+	}
+}
+
+// Tests that requests for unknown paths fail the test.
+func Test_Server_UnexpectedRequest(t *testing.T) {
+	// given
+	tb := &recordingTB{TB: t}
+	server := bulktest.NewServer(tb)
+
+	// when
+	res, err := http.Get(server.URL + "/unknown")
+
+	// then
+	if err != nil || res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected not found response, got %v", err)
+	} else {
+		res.Body.Close()
+	}
+
+	if len(tb.errors) != 1 {
+		t.Errorf("expected test to fail, got %v", tb.errors)
+	}
+}
@@ -0,0 +1,343 @@
+// Package bulktest provides utilities for deterministically testing code,
+// which issues requests via a bulk.Executor.
+package bulktest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnmatchedRequest = errors.New("no recorded interaction matches the request")
+)
+
+// Mode is the mode of operation of a Transport.
+type Mode int
+
+const (
+	// ModeReplay serves recorded interactions, and fails for all unmatched requests.
+	ModeReplay Mode = iota
+	// ModeRecord issues all requests via the next http.RoundTripper, and records them.
+	ModeRecord
+)
+
+// TransportOptions is the option-wrapper for defining the workings of a Transport.
+type TransportOptions struct {
+	Next http.RoundTripper
+
+	MatchMethod  bool
+	MatchURL     bool
+	MatchHeaders []string
+	MatchBody    bool
+}
+
+type TransportOption func(*TransportOptions)
+
+// Next sets the http.RoundTripper, which issues the requests in ModeRecord.
+// Per default, the http.DefaultTransport is used.
+func Next(next http.RoundTripper) TransportOption {
+	return func(args *TransportOptions) {
+		args.Next = next
+	}
+}
+
+// MatchMethod regulates whether the method must match. Per default, it must.
+func MatchMethod(match bool) TransportOption {
+	return func(args *TransportOptions) {
+		args.MatchMethod = match
+	}
+}
+
+// MatchURL regulates whether the url (including the query) must match. Per default, it must.
+func MatchURL(match bool) TransportOption {
+	return func(args *TransportOptions) {
+		args.MatchURL = match
+	}
+}
+
+// MatchHeaders sets the names of headers, whose values must match. Per default, no headers must match.
+func MatchHeaders(names ...string) TransportOption {
+	return func(args *TransportOptions) {
+		args.MatchHeaders = names
+	}
+}
+
+// MatchBody regulates whether the (sha256 hash of the) request body must match.
+// Per default, it does not need to.
+func MatchBody(match bool) TransportOption {
+	return func(args *TransportOptions) {
+		args.MatchBody = match
+	}
+}
+
+// Transport is a http.RoundTripper, which either records interactions into a fixtures
+// directory, or replays them from there. Each interaction is saved as a json file, named
+// after the hash of the matched request properties - and numbered, if the same request
+// is issued multiple times. On replay, repeated requests are served the recorded responses
+// in order, with the last one being repeated as required.
+type Transport struct {
+	t    testing.TB
+	dir  string
+	mode Mode
+	args *TransportOptions
+
+	mutex    sync.Mutex
+	counters map[string]int
+}
+
+// NewTransport instantiates a new Transport, operating on the given fixtures directory.
+// If a request cannot be matched, the test fails via t.Errorf, and an error wrapping
+// ErrUnmatchedRequest is returned to the client. Failures to record are reported likewise.
+func NewTransport(t testing.TB, dir string, mode Mode, setters ...TransportOption) *Transport {
+	// Default Options
+	args := &TransportOptions{
+		Next:        http.DefaultTransport,
+		MatchMethod: true,
+		MatchURL:    true,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	return &Transport{
+		t:        t,
+		dir:      dir,
+		mode:     mode,
+		args:     args,
+		counters: map[string]int{},
+	}
+}
+
+// Client returns a http.Client using the Transport, for passing it via bulk.Client.
+func (tr *Transport) Client() *http.Client {
+	return &http.Client{Transport: tr}
+}
+
+type fixture struct {
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureRequest struct {
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Header   http.Header `json:"header,omitempty"`
+	BodyHash string      `json:"bodyHash,omitempty"`
+}
+
+type fixtureResponse struct {
+	StatusCode   int         `json:"statusCode"`
+	Header       http.Header `json:"header"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"bodyEncoding,omitempty"`
+}
+
+// RoundTrip implements http.RoundTripper.
+func (tr *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := tr.matchedRequest(req, body)
+	key := matched.key()
+
+	tr.mutex.Lock()
+	occurrence := tr.counters[key]
+	tr.counters[key]++
+	tr.mutex.Unlock()
+
+	if tr.mode == ModeRecord {
+		return tr.record(req, body, matched, key, occurrence)
+	}
+
+	return tr.replay(req, matched, key, occurrence)
+}
+
+func (tr *Transport) record(req *http.Request, body []byte, matched fixtureRequest, key string, occurrence int) (*http.Response, error) {
+	// the body was consumed for hashing, so it is restored for the next round tripper
+	outgoing := req.Clone(req.Context())
+	if req.Body != nil {
+		outgoing.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	res, err := tr.args.Next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := fixture{
+		Request: matched,
+		Response: fixtureResponse{
+			StatusCode: res.StatusCode,
+			Header:     res.Header,
+		},
+	}
+
+	if utf8.Valid(resBody) {
+		recorded.Response.Body = string(resBody)
+	} else {
+		recorded.Response.Body = base64.StdEncoding.EncodeToString(resBody)
+		recorded.Response.BodyEncoding = "base64"
+	}
+
+	if err := tr.save(recorded, key, occurrence); err != nil {
+		return nil, tr.fail(req, err)
+	}
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(resBody))
+	return res, nil
+}
+
+func (tr *Transport) save(recorded fixture, key string, occurrence int) error {
+	if err := os.MkdirAll(tr.dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(recorded, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(tr.path(key, occurrence), data, 0644)
+}
+
+func (tr *Transport) replay(req *http.Request, matched fixtureRequest, key string, occurrence int) (*http.Response, error) {
+	// repeat the last recorded response, if the request was issued more often than recorded
+	var data []byte
+	for ; occurrence >= 0; occurrence-- {
+		var err error
+		if data, err = ioutil.ReadFile(tr.path(key, occurrence)); err == nil {
+			break
+		}
+	}
+
+	if data == nil {
+		return nil, tr.fail(req, fmt.Errorf("matched as %+v: %w", matched, ErrUnmatchedRequest))
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, tr.fail(req, err)
+	}
+
+	body := []byte(recorded.Response.Body)
+	if recorded.Response.BodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(recorded.Response.Body)
+		if err != nil {
+			return nil, tr.fail(req, err)
+		}
+		body = decoded
+	}
+
+	header := recorded.Response.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+		StatusCode:    recorded.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// fail reports the error loudly via the test, and returns it for the client.
+func (tr *Transport) fail(req *http.Request, err error) error {
+	if tr.t != nil {
+		tr.t.Errorf("bulktest: %s %s: %s", req.Method, req.URL, err)
+	}
+
+	return err
+}
+
+func (tr *Transport) path(key string, occurrence int) string {
+	name := key
+	if occurrence > 0 {
+		name = fmt.Sprintf("%s-%d", key, occurrence)
+	}
+
+	return filepath.Join(tr.dir, name+".json")
+}
+
+// matchedRequest reduces the request to the properties, which must match.
+func (tr *Transport) matchedRequest(req *http.Request, body []byte) fixtureRequest {
+	var matched fixtureRequest
+	if tr.args.MatchMethod {
+		matched.Method = req.Method
+	}
+
+	if tr.args.MatchURL {
+		matched.URL = req.URL.String()
+	}
+
+	for _, name := range tr.args.MatchHeaders {
+		if values := req.Header.Values(name); len(values) > 0 {
+			if matched.Header == nil {
+				matched.Header = http.Header{}
+			}
+			matched.Header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	if tr.args.MatchBody && len(body) > 0 {
+		hash := sha256.Sum256(body)
+		matched.BodyHash = hex.EncodeToString(hash[:])
+	}
+
+	return matched
+}
+
+// key derives a stable file name from the matched request properties.
+func (r fixtureRequest) key() string {
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{r.Method, r.URL, r.BodyHash}
+	for _, name := range names {
+		parts = append(parts, name+": "+strings.Join(r.Header[name], ", "))
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(hash[:16])
+}
+
+// readRequestBody reads (and closes) the body of the request, as mandated for round trippers.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	defer req.Body.Close()
+	return ioutil.ReadAll(req.Body)
+}
@@ -0,0 +1,142 @@
+package bulktest_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+	"github.com/kernle32dll/httpbulk-go/bulktest"
+
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// recordingTB captures errors, instead of failing the test.
+type recordingTB struct {
+	testing.TB
+
+	mutex  sync.Mutex
+	errors []string
+}
+
+func (tb *recordingTB) Errorf(format string, args ...interface{}) {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func readResult(t *testing.T, result bulk.Result) string {
+	t.Helper()
+
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+	defer result.Res().Body.Close()
+
+	body, _ := ioutil.ReadAll(result.Res().Body)
+	return fmt.Sprintf("%d %s", result.Res().StatusCode, body)
+}
+
+// Tests that recorded interactions are replayed, with repeated requests served in order.
+func Test_Transport_RecordReplay(t *testing.T) {
+	// given
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := atomic.AddInt32(&calls, 1)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, "%s %s #%d", r.Header.Get("Accept-Language"), r.URL.Path, call)
+	}))
+
+	dir, err := ioutil.TempDir("", "bulktest")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	issue := func(executor *bulk.Executor, path, language string) bulk.Result {
+		return <-executor.AddRequestsWithInterceptor(context.Background(), func(r *http.Request) error {
+			r.Header.Set("Accept-Language", language)
+			return nil
+		}, server.URL+path)[0]
+	}
+
+	recorder := bulk.NewExecutor(bulk.ConcurrencyLimit(1), bulk.Client(
+		bulktest.NewTransport(t, dir, bulktest.ModeRecord, bulktest.MatchHeaders("Accept-Language")).Client(),
+	))
+	defer recorder.Close()
+
+	recorded := []string{
+		readResult(t, issue(recorder, "/a", "en")),
+		readResult(t, issue(recorder, "/a", "en")),
+		readResult(t, issue(recorder, "/a", "de")),
+		readResult(t, issue(recorder, "/missing", "en")),
+	}
+	server.Close()
+
+	replayer := bulk.NewExecutor(bulk.ConcurrencyLimit(1), bulk.Client(
+		bulktest.NewTransport(t, dir, bulktest.ModeReplay, bulktest.MatchHeaders("Accept-Language")).Client(),
+	))
+	defer replayer.Close()
+
+	// when
+	replayed := []string{
+		readResult(t, issue(replayer, "/a", "en")),
+		readResult(t, issue(replayer, "/a", "en")),
+		readResult(t, issue(replayer, "/a", "de")),
+		readResult(t, issue(replayer, "/missing", "en")),
+	}
+	repeated := readResult(t, issue(replayer, "/a", "en"))
+
+	// then
+	expected := []string{"200 en /a #1", "200 en /a #2", "200 de /a #3", "404 en /missing #4"}
+	for i := range expected {
+		if recorded[i] != expected[i] || replayed[i] != expected[i] {
+			t.Errorf("interaction %d: expected %q, got %q recorded and %q replayed", i, expected[i], recorded[i], replayed[i])
+		}
+	}
+
+	if repeated != expected[1] {
+		t.Errorf("expected last response to be repeated, got %q", repeated)
+	}
+}
+
+// Tests that unmatched requests fail loudly.
+func Test_Transport_Unmatched(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "bulktest")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tb := &recordingTB{TB: t}
+	executor := bulk.NewExecutor(bulk.Client(
+		bulktest.NewTransport(tb, dir, bulktest.ModeReplay, bulktest.MatchBody(true)).Client(),
+	))
+	defer executor.Close()
+
+	// when
+	result := <-executor.AddRequestsWithInterceptor(context.Background(), func(r *http.Request) error {
+		r.Method = http.MethodPost
+		r.Body = ioutil.NopCloser(strings.NewReader("payload"))
+		return nil
+	}, "https://example.com/unknown")[0]
+
+	// then
+	if !errors.Is(result.Err(), bulktest.ErrUnmatchedRequest) {
+		t.Errorf("expected unmatched request error, got %v", result.Err())
+	}
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "https://example.com/unknown") {
+		t.Errorf("expected test to fail, got %v", tb.errors)
+	}
+}
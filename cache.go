@@ -0,0 +1,381 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a single response stored in a CacheStorage.
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	// VaryHeader holds the request headers listed in the Vary header of the
+	// response, for matching subsequent requests against.
+	VaryHeader http.Header
+
+	// ResponseTime is the time the response was received.
+	ResponseTime time.Time
+}
+
+func (entry *CacheEntry) size() int64 {
+	size := int64(len(entry.Body))
+	for _, header := range []http.Header{entry.Header, entry.VaryHeader} {
+		for name, values := range header {
+			for _, value := range values {
+				size += int64(len(name) + len(value))
+			}
+		}
+	}
+
+	return size
+}
+
+// CacheStorage stores responses for usage with the Cache option. Implementations
+// must be safe for concurrent use. As a cache is an optimization only, failures
+// (e.g. of a disk) should be treated as misses by implementations.
+type CacheStorage interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry)
+	Delete(key string)
+}
+
+type cacheState int
+
+const (
+	cacheMiss cacheState = iota
+	cacheFresh
+	cacheStaleWhileRevalidate
+	cacheStaleIfError
+)
+
+// responseCache implements the caching semantics of RFC 7234 on top of a CacheStorage.
+type responseCache struct {
+	storage CacheStorage
+	shared  bool
+	now     func() time.Time
+
+	mutex      sync.Mutex
+	refreshing map[string]struct{}
+}
+
+func newResponseCache(storage CacheStorage, shared bool) *responseCache {
+	return &responseCache{
+		storage:    storage,
+		shared:     shared,
+		now:        time.Now,
+		refreshing: map[string]struct{}{},
+	}
+}
+
+func cacheKey(req *http.Request) string {
+	return req.Method + " " + req.URL.String()
+}
+
+// lookup returns the stored entry for the request (if any), and whether it may be served.
+func (c *responseCache) lookup(req *http.Request) (*CacheEntry, cacheState) {
+	if req.Method != http.MethodGet {
+		return nil, cacheMiss
+	}
+
+	requestDirectives := parseCacheControl(req.Header)
+	if _, ok := requestDirectives["no-store"]; ok {
+		return nil, cacheMiss
+	}
+	if _, ok := requestDirectives["no-cache"]; ok {
+		return nil, cacheMiss
+	}
+
+	entry, ok := c.storage.Get(cacheKey(req))
+	if !ok || !varyMatches(entry, req) {
+		return nil, cacheMiss
+	}
+
+	lifetime, _ := c.freshnessLifetime(entry)
+	age := c.age(entry)
+	if age < lifetime {
+		return entry, cacheFresh
+	}
+
+	responseDirectives := parseCacheControl(entry.Header)
+	if _, ok := responseDirectives["must-revalidate"]; ok {
+		return nil, cacheMiss
+	}
+	if _, ok := responseDirectives["proxy-revalidate"]; ok && c.shared {
+		return nil, cacheMiss
+	}
+
+	staleness := age - lifetime
+	if window, ok := directiveSeconds(responseDirectives, "stale-while-revalidate"); ok && staleness <= window {
+		return entry, cacheStaleWhileRevalidate
+	}
+
+	if window, ok := directiveSeconds(responseDirectives, "stale-if-error"); ok && staleness <= window {
+		return entry, cacheStaleIfError
+	}
+
+	return nil, cacheMiss
+}
+
+// handle processes the outcome of a request, which was not served from the cache. Storable
+// responses are stored, and errors are replaced by the stale entry, if permitted.
+func (c *responseCache) handle(
+	req *http.Request,
+	res *http.Response,
+	err error,
+	stale *CacheEntry,
+	state cacheState,
+) (*http.Response, bool, error) {
+	if state == cacheStaleIfError && (err != nil || res.StatusCode >= 500) {
+		if res != nil {
+			res.Body.Close()
+		}
+
+		return c.response(req, stale), true, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	// unsafe methods invalidate the stored response for the url
+	if req.Method != http.MethodGet && req.Method != http.MethodHead && res.StatusCode < 400 {
+		c.storage.Delete(http.MethodGet + " " + req.URL.String())
+		return res, false, nil
+	}
+
+	if !c.storable(req, res) {
+		return res, false, nil
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entry := &CacheEntry{
+		StatusCode:   res.StatusCode,
+		Header:       res.Header.Clone(),
+		Body:         body,
+		VaryHeader:   http.Header{},
+		ResponseTime: c.now(),
+	}
+	for _, name := range varyHeaders(res.Header) {
+		entry.VaryHeader[name] = req.Header.Values(name)
+	}
+	c.storage.Set(cacheKey(req), entry)
+
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, false, nil
+}
+
+// refresh revalidates a stale entry in the background, unless this is already
+// in progress for the same request.
+func (c *responseCache) refresh(req *http.Request, send func(req *http.Request) (*http.Response, error)) {
+	key := cacheKey(req)
+
+	c.mutex.Lock()
+	if _, ok := c.refreshing[key]; ok {
+		c.mutex.Unlock()
+		return
+	}
+	c.refreshing[key] = struct{}{}
+	c.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			delete(c.refreshing, key)
+			c.mutex.Unlock()
+		}()
+
+		// the request context belongs to the caller, who already got the stale response
+		res, err := send(req.Clone(context.Background()))
+		if res, _, err := c.handle(req, res, err, nil, cacheMiss); err == nil {
+			res.Body.Close()
+		}
+	}()
+}
+
+// response creates a new http response from the given entry.
+func (c *responseCache) response(req *http.Request, entry *CacheEntry) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(c.age(entry)/time.Second), 10))
+
+	return &http.Response{
+		Status:        strconv.Itoa(entry.StatusCode) + " " + http.StatusText(entry.StatusCode),
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+func (c *responseCache) storable(req *http.Request, res *http.Response) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusGone, http.StatusRequestURITooLong,
+		http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	requestDirectives := parseCacheControl(req.Header)
+	if _, ok := requestDirectives["no-store"]; ok {
+		return false
+	}
+
+	responseDirectives := parseCacheControl(res.Header)
+	for _, directive := range []string{"no-store", "no-cache"} {
+		if _, ok := responseDirectives[directive]; ok {
+			return false
+		}
+	}
+
+	if c.shared {
+		if _, ok := responseDirectives["private"]; ok {
+			return false
+		}
+
+		if req.Header.Get("Authorization") != "" {
+			_, public := responseDirectives["public"]
+			_, sMaxAge := responseDirectives["s-maxage"]
+			_, mustRevalidate := responseDirectives["must-revalidate"]
+			if !public && !sMaxAge && !mustRevalidate {
+				return false
+			}
+		}
+	}
+
+	for _, name := range varyHeaders(res.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+
+	_, ok := c.freshnessLifetime(&CacheEntry{Header: res.Header})
+	return ok
+}
+
+// freshnessLifetime returns the freshness lifetime of the entry, and false if
+// the entry carries no explicit freshness information.
+func (c *responseCache) freshnessLifetime(entry *CacheEntry) (time.Duration, bool) {
+	directives := parseCacheControl(entry.Header)
+
+	if c.shared {
+		if lifetime, ok := directiveSeconds(directives, "s-maxage"); ok {
+			return lifetime, true
+		}
+	}
+
+	if lifetime, ok := directiveSeconds(directives, "max-age"); ok {
+		return lifetime, true
+	}
+
+	if rawExpires := entry.Header.Get("Expires"); rawExpires != "" {
+		expires, err := http.ParseTime(rawExpires)
+		if err != nil {
+			// invalid dates, such as "0", represent a time in the past
+			return 0, true
+		}
+
+		date, err := http.ParseTime(entry.Header.Get("Date"))
+		if err != nil {
+			date = entry.ResponseTime
+		}
+
+		return expires.Sub(date), true
+	}
+
+	return 0, false
+}
+
+func (c *responseCache) age(entry *CacheEntry) time.Duration {
+	age := c.now().Sub(entry.ResponseTime)
+	if seconds, err := strconv.ParseInt(entry.Header.Get("Age"), 10, 64); err == nil && seconds > 0 {
+		age += time.Duration(seconds) * time.Second
+	}
+
+	return age
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+func varyMatches(entry *CacheEntry, req *http.Request) bool {
+	for _, name := range varyHeaders(entry.Header) {
+		if name == "*" {
+			return false
+		}
+
+		if strings.Join(entry.VaryHeader.Values(name), ",") != strings.Join(req.Header.Values(name), ",") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseCacheControl parses all Cache-Control headers into a map of
+// lower-cased directives to their (unquoted) values.
+func parseCacheControl(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+
+			name, argument := directive, ""
+			if i := strings.IndexByte(directive, '='); i >= 0 {
+				name, argument = directive[:i], strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+			}
+
+			directives[strings.ToLower(strings.TrimSpace(name))] = argument
+		}
+	}
+
+	return directives
+}
+
+func directiveSeconds(directives map[string]string, name string) (time.Duration, bool) {
+	value, ok := directives[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,165 @@
+package bulk
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryCacheStorage is an in-memory CacheStorage, which evicts the least
+// recently used entries once its size budget is exceeded.
+type MemoryCacheStorage struct {
+	maxBytes int64
+
+	mutex   sync.Mutex
+	size    int64
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+	size  int64
+}
+
+// NewMemoryCacheStorage instantiates a new MemoryCacheStorage with the given size
+// budget in bytes (approximated by the size of bodies and headers). Entries which
+// exceed the budget on their own are not stored at all.
+func NewMemoryCacheStorage(maxBytes int64) *MemoryCacheStorage {
+	return &MemoryCacheStorage{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+	}
+}
+
+// Get returns the entry for the given key, if any.
+func (storage *MemoryCacheStorage) Get(key string) (*CacheEntry, bool) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	element, ok := storage.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	storage.order.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+// Set stores the entry for the given key, replacing any previous one.
+func (storage *MemoryCacheStorage) Set(key string, entry *CacheEntry) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.remove(key)
+
+	item := &memoryCacheItem{key: key, entry: entry, size: entry.size()}
+	if item.size > storage.maxBytes {
+		return
+	}
+
+	storage.entries[key] = storage.order.PushFront(item)
+	storage.size += item.size
+
+	for storage.size > storage.maxBytes {
+		storage.remove(storage.order.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+// Delete removes the entry for the given key, if any.
+func (storage *MemoryCacheStorage) Delete(key string) {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	storage.remove(key)
+}
+
+// Size returns the current size of all stored entries in bytes.
+func (storage *MemoryCacheStorage) Size() int64 {
+	storage.mutex.Lock()
+	defer storage.mutex.Unlock()
+
+	return storage.size
+}
+
+func (storage *MemoryCacheStorage) remove(key string) {
+	element, ok := storage.entries[key]
+	if !ok {
+		return
+	}
+
+	storage.order.Remove(element)
+	delete(storage.entries, key)
+	storage.size -= element.Value.(*memoryCacheItem).size
+}
+
+// DiskCacheStorage is a CacheStorage, which stores each entry as a json
+// file in a directory. Entries are never evicted automatically.
+type DiskCacheStorage struct {
+	dir string
+}
+
+// NewDiskCacheStorage instantiates a new DiskCacheStorage, creating the
+// directory if necessary.
+func NewDiskCacheStorage(dir string) (*DiskCacheStorage, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &DiskCacheStorage{dir: dir}, nil
+}
+
+// Get returns the entry for the given key, if any. Unreadable entries are reported as missing.
+func (storage *DiskCacheStorage) Get(key string) (*CacheEntry, bool) {
+	content, err := ioutil.ReadFile(storage.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+
+	return &entry, true
+}
+
+// Set stores the entry for the given key, replacing any previous one. The file
+// is replaced atomically, so concurrent readers never observe partial entries.
+func (storage *DiskCacheStorage) Set(key string, entry *CacheEntry) {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	file, err := ioutil.TempFile(storage.dir, ".tmp-")
+	if err != nil {
+		return
+	}
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil || os.Rename(file.Name(), storage.path(key)) != nil {
+		os.Remove(file.Name())
+	}
+}
+
+// Delete removes the entry for the given key, if any.
+func (storage *DiskCacheStorage) Delete(key string) {
+	os.Remove(storage.path(key))
+}
+
+func (storage *DiskCacheStorage) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(storage.dir, hex.EncodeToString(hash[:])+".json")
+}
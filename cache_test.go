@@ -0,0 +1,307 @@
+package bulk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+
+	clock.now = clock.now.Add(d)
+}
+
+func newCachingExecutor(setters ...Option) (*Executor, *fakeClock) {
+	executor := NewExecutor(append([]Option{Cache(NewMemoryCacheStorage(1 << 20))}, setters...)...)
+
+	clock := &fakeClock{now: time.Now()}
+	executor.cache.now = clock.Now
+
+	return executor, clock
+}
+
+func fetchBody(t *testing.T, executor *Executor, modifyRequest func(r *http.Request) error, url string) (Result, string) {
+	result := <-executor.AddRequestsWithInterceptor(context.Background(), modifyRequest, url)[0]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+
+	defer result.Res().Body.Close()
+	body, err := ioutil.ReadAll(result.Res().Body)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	return result, string(body)
+}
+
+// Tests that fresh responses are served from the cache, until they become stale.
+func Test_Cache_Fresh(t *testing.T) {
+	// given
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	executor, clock := newCachingExecutor()
+	defer executor.Close()
+
+	// when
+	first, _ := fetchBody(t, executor, nil, server.URL)
+	second, body := fetchBody(t, executor, nil, server.URL)
+
+	clock.Advance(time.Minute)
+	third, _ := fetchBody(t, executor, nil, server.URL)
+
+	// then
+	if first.CacheHit() || !second.CacheHit() || third.CacheHit() {
+		t.Errorf("unexpected cache hits: %t, %t, %t", first.CacheHit(), second.CacheHit(), third.CacheHit())
+	}
+
+	if body != "payload" {
+		t.Errorf("unexpected cached body %q", body)
+	}
+
+	if hits := atomic.LoadInt32(&hits); hits != 2 {
+		t.Errorf("expected 2 requests to the server, got %d", hits)
+	}
+}
+
+// Tests which responses are not stored.
+func Test_Cache_NotStorable(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheControl  string
+		authorization string
+		private       bool
+		expectHit     bool
+	}{
+		{name: "no-store", cacheControl: "no-store, max-age=60"},
+		{name: "no freshness", cacheControl: ""},
+		{name: "private in shared cache", cacheControl: "private, max-age=60"},
+		{name: "private in private cache", cacheControl: "private, max-age=60", private: true, expectHit: true},
+		{name: "authorization in shared cache", cacheControl: "max-age=60", authorization: "secret"},
+		{name: "authorization with public", cacheControl: "public, max-age=60", authorization: "secret", expectHit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// given
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", tt.cacheControl)
+			}))
+			defer server.Close()
+
+			executor, _ := newCachingExecutor(PrivateCache(tt.private))
+			defer executor.Close()
+
+			modifyRequest := func(r *http.Request) error {
+				if tt.authorization != "" {
+					r.Header.Set("Authorization", tt.authorization)
+				}
+				return nil
+			}
+
+			// when
+			fetchBody(t, executor, modifyRequest, server.URL)
+			result, _ := fetchBody(t, executor, modifyRequest, server.URL)
+
+			// then
+			if result.CacheHit() != tt.expectHit {
+				t.Errorf("expected cache hit %t", tt.expectHit)
+			}
+		})
+	}
+}
+
+// Tests that responses are only served for matching Vary headers.
+func Test_Cache_Vary(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	executor, _ := newCachingExecutor()
+	defer executor.Close()
+
+	language := func(language string) func(r *http.Request) error {
+		return func(r *http.Request) error {
+			r.Header.Set("Accept-Language", language)
+			return nil
+		}
+	}
+
+	// when
+	fetchBody(t, executor, language("de"), server.URL)
+	sameLanguage, _ := fetchBody(t, executor, language("de"), server.URL)
+	otherLanguage, body := fetchBody(t, executor, language("en"), server.URL)
+
+	// then
+	if !sameLanguage.CacheHit() {
+		t.Error("expected cache hit for same language")
+	}
+
+	if otherLanguage.CacheHit() || body != "en" {
+		t.Errorf("expected cache miss for other language, got %q", body)
+	}
+}
+
+// Tests that stale responses are served while revalidating in the background.
+func Test_Cache_StaleWhileRevalidate(t *testing.T) {
+	// given
+	var hits int32
+	refreshed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 2 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=60")
+	}))
+	defer server.Close()
+
+	executor, clock := newCachingExecutor()
+	defer executor.Close()
+
+	// when
+	fetchBody(t, executor, nil, server.URL)
+	clock.Advance(90 * time.Second)
+	stale, _ := fetchBody(t, executor, nil, server.URL)
+
+	// then
+	if !stale.CacheHit() {
+		t.Error("expected stale response to be served from the cache")
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale response was not revalidated in the background")
+	}
+}
+
+// Tests that stale responses are served, if the server fails.
+func Test_Cache_StaleIfError(t *testing.T) {
+	// given
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, stale-if-error=60")
+		if atomic.AddInt32(&hits, 1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	executor, clock := newCachingExecutor()
+	defer executor.Close()
+
+	// when
+	fetchBody(t, executor, nil, server.URL)
+	clock.Advance(90 * time.Second)
+	withinWindow, body := fetchBody(t, executor, nil, server.URL)
+	clock.Advance(time.Minute)
+	afterWindow, _ := fetchBody(t, executor, nil, server.URL)
+
+	// then
+	if !withinWindow.CacheHit() || body != "payload" {
+		t.Errorf("expected stale response within stale-if-error window, got %q", body)
+	}
+
+	if afterWindow.CacheHit() || afterWindow.Res().StatusCode != http.StatusServiceUnavailable {
+		t.Error("expected error response after stale-if-error window")
+	}
+}
+
+// Tests that the least recently used entries are evicted once the budget is exceeded.
+func Test_MemoryCacheStorage_Eviction(t *testing.T) {
+	// given
+	storage := NewMemoryCacheStorage(25)
+
+	// when
+	storage.Set("a", &CacheEntry{Body: make([]byte, 10)})
+	storage.Set("b", &CacheEntry{Body: make([]byte, 10)})
+	storage.Get("a")
+	storage.Set("c", &CacheEntry{Body: make([]byte, 10)})
+	storage.Set("d", &CacheEntry{Body: make([]byte, 100)})
+
+	// then
+	if _, ok := storage.Get("b"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+
+	if _, ok := storage.Get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	if _, ok := storage.Get("d"); ok {
+		t.Error("entry exceeding the budget was stored")
+	}
+
+	if storage.Size() != 20 {
+		t.Errorf("expected size 20, got %d", storage.Size())
+	}
+}
+
+// Tests that entries survive a roundtrip through the disk storage.
+func Test_DiskCacheStorage(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "httpbulk-cache")
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	storage, err := NewDiskCacheStorage(dir)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	entry := &CacheEntry{
+		StatusCode:   http.StatusOK,
+		Header:       http.Header{"Cache-Control": {"max-age=60"}},
+		Body:         []byte("payload"),
+		ResponseTime: time.Unix(1624579200, 0),
+	}
+
+	// when
+	storage.Set("GET https://example.com", entry)
+	stored, ok := storage.Get("GET https://example.com")
+
+	// then
+	if !ok || string(stored.Body) != "payload" || stored.Header.Get("Cache-Control") != "max-age=60" ||
+		!stored.ResponseTime.Equal(entry.ResponseTime) {
+		t.Errorf("entry not correctly stored, got %+v", stored)
+	}
+
+	storage.Delete("GET https://example.com")
+	if _, ok := storage.Get("GET https://example.com"); ok {
+		t.Error("entry not deleted")
+	}
+}
@@ -0,0 +1,170 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ErrorKind is a coarse classification of a URLError.
+type ErrorKind string
+
+const (
+	ErrorKindCanceled  ErrorKind = "canceled"
+	ErrorKindTimeout   ErrorKind = "timeout"
+	ErrorKindStatus    ErrorKind = "status"
+	ErrorKindTransport ErrorKind = "transport"
+	ErrorKindOther     ErrorKind = "other"
+)
+
+// URLError is the error of a single url within a bulk of requests.
+type URLError struct {
+	// Index is the position of the url within the issued bulk.
+	Index int
+	// Key is the key of the request, if issued via one of the keyed methods.
+	Key string
+	URL string
+	// StatusCode is the status code of the response, or 0 if no response was received.
+	StatusCode int
+	Err        error
+}
+
+func newURLError(index int, r Result, err error) *URLError {
+	urlErr := &URLError{Index: index, Key: r.Key(), URL: r.URL(), Err: err}
+	if r.res != nil {
+		urlErr.StatusCode = r.res.StatusCode
+	}
+
+	return urlErr
+}
+
+func (e *URLError) Error() string {
+	return fmt.Sprintf("#%d %s: %s", e.Index, e.URL, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// Kind classifies the error.
+func (e *URLError) Kind() ErrorKind {
+	var netErr net.Error
+
+	switch {
+	case errors.Is(e.Err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.As(e.Err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout
+	case errors.Is(e.Err, ErrRequestFailed):
+		return ErrorKindStatus
+	case netErr != nil:
+		return ErrorKindTransport
+	default:
+		return ErrorKindOther
+	}
+}
+
+// BulkError collects the errors of all failed urls within a bulk of requests.
+// errors.Is and errors.As match, if they match any of the contained errors.
+type BulkError []*URLError
+
+func (e BulkError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d requests failed: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Is reports whether any of the contained errors matches target.
+func (e BulkError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first contained error that matches target, and if so,
+// sets target to that error value and returns true.
+func (e BulkError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ByKind groups the contained errors by their ErrorKind.
+func (e BulkError) ByKind() map[ErrorKind]BulkError {
+	groups := map[ErrorKind]BulkError{}
+	for _, err := range e {
+		groups[err.Kind()] = append(groups[err.Kind()], err)
+	}
+
+	return groups
+}
+
+// ByStatusCode groups the contained errors by the status code of their
+// response. Errors without a response are grouped under 0.
+func (e BulkError) ByStatusCode() map[int]BulkError {
+	groups := map[int]BulkError{}
+	for _, err := range e {
+		groups[err.StatusCode] = append(groups[err.StatusCode], err)
+	}
+
+	return groups
+}
+
+// Summary returns a short, human readable overview of the contained
+// errors, e.g. "3 failed (status: 2, timeout: 1)".
+func (e BulkError) Summary() string {
+	groups := e.ByKind()
+
+	kinds := make([]string, 0, len(groups))
+	for kind, errs := range groups {
+		kinds = append(kinds, fmt.Sprintf("%s: %d", kind, len(errs)))
+	}
+	sort.Strings(kinds)
+
+	return fmt.Sprintf("%d failed (%s)", len(e), strings.Join(kinds, ", "))
+}
+
+// WaitAll waits for all given futures to finish. A future counts as failed,
+// if its Result carries an error, or its response has a non 2xx status code.
+// If any future failed, a BulkError is returned.
+func WaitAll(futures ...*Future) error {
+	var bulkErr BulkError
+	for i, future := range futures {
+		result := future.Get()
+		if err := result.Err(); err != nil {
+			bulkErr = append(bulkErr, newURLError(i, result, err))
+			continue
+		}
+
+		if status := result.res.StatusCode; status < 200 || status > 299 {
+			bulkErr = append(bulkErr, newURLError(i, result, fmt.Errorf("unexpected status %d: %w", status, ErrRequestFailed)))
+		}
+	}
+
+	if len(bulkErr) > 0 {
+		return bulkErr
+	}
+
+	return nil
+}
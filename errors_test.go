@@ -0,0 +1,109 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type statusError struct {
+	status int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", e.status)
+}
+
+// Tests that errors.Is and errors.As match any of the contained errors.
+func Test_BulkError_IsAs(t *testing.T) {
+	// given
+	referenceErr := errors.New("expected error")
+	bulkErr := BulkError{
+		{Index: 0, URL: "a", Err: referenceErr},
+		{Index: 1, URL: "b", Err: fmt.Errorf("wrapped: %w", statusError{status: 418})},
+	}
+
+	// then
+	if !errors.Is(bulkErr, referenceErr) {
+		t.Error("errors.Is did not match first member")
+	}
+
+	if errors.Is(bulkErr, context.Canceled) {
+		t.Error("errors.Is matched unrelated error")
+	}
+
+	var target statusError
+	if !errors.As(bulkErr, &target) || target.status != 418 {
+		t.Error("errors.As did not match second member")
+	}
+
+	var urlErr *URLError
+	if !errors.As(bulkErr, &urlErr) || urlErr.Index != 0 {
+		t.Error("errors.As did not match first url error")
+	}
+}
+
+// Tests that the errors are correctly grouped by kind and status code.
+func Test_BulkError_Grouping(t *testing.T) {
+	// given
+	bulkErr := BulkError{
+		{Index: 0, StatusCode: 500, Err: ErrRequestFailed},
+		{Index: 1, StatusCode: 502, Err: ErrRequestFailed},
+		{Index: 2, Err: context.DeadlineExceeded},
+		{Index: 3, Err: fmt.Errorf("wrapped: %w", context.Canceled)},
+		{Index: 4, StatusCode: 500, Err: ErrRequestFailed},
+	}
+
+	// when
+	byKind := bulkErr.ByKind()
+	byStatus := bulkErr.ByStatusCode()
+
+	// then
+	if len(byKind[ErrorKindStatus]) != 3 || len(byKind[ErrorKindTimeout]) != 1 || len(byKind[ErrorKindCanceled]) != 1 {
+		t.Errorf("unexpected grouping by kind: %v", byKind)
+	}
+
+	if len(byStatus[500]) != 2 || len(byStatus[502]) != 1 || len(byStatus[0]) != 2 {
+		t.Errorf("unexpected grouping by status: %v", byStatus)
+	}
+
+	if summary := bulkErr.Summary(); summary != "5 failed (canceled: 1, status: 3, timeout: 1)" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+// Tests that WaitAll reports failed futures with their index.
+func Test_WaitAll(t *testing.T) {
+	// given
+	referenceErr := errors.New("expected error")
+
+	futures := make([]*Future, 3)
+	for i, result := range []Result{
+		{url: "ok", res: &http.Response{StatusCode: http.StatusOK}},
+		{url: "failed", err: referenceErr},
+		{url: "missing", res: &http.Response{StatusCode: http.StatusNotFound}},
+	} {
+		resultChan := make(chan Result, 1)
+		resultChan <- result
+		futures[i] = &Future{resultChan: resultChan}
+	}
+
+	// when
+	err := WaitAll(futures...)
+
+	// then
+	var bulkErr BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr) != 2 {
+		t.Fatalf("expected bulk error with two members, got %v", err)
+	}
+
+	if bulkErr[0].Index != 1 || !errors.Is(bulkErr[0], referenceErr) {
+		t.Errorf("unexpected first error %s", bulkErr[0])
+	}
+
+	if bulkErr[1].Index != 2 || bulkErr[1].StatusCode != http.StatusNotFound || !errors.Is(bulkErr[1], ErrRequestFailed) {
+		t.Errorf("unexpected second error %s", bulkErr[1])
+	}
+}
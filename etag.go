@@ -0,0 +1,249 @@
+package bulk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMissingETag = errors.New("missing or invalid etag header")
+)
+
+// ETag is a parsed entity tag, as defined in RFC 7232.
+type ETag struct {
+	// Value is the opaque tag, without quotes.
+	Value string
+	Weak  bool
+}
+
+// ParseETag parses the value of an ETag header, such as `"xyz"` or `W/"xyz"`.
+func ParseETag(header string) (ETag, error) {
+	header = strings.TrimSpace(header)
+
+	var etag ETag
+	if strings.HasPrefix(header, "W/") {
+		etag.Weak = true
+		header = header[2:]
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return ETag{}, fmt.Errorf("%q: %w", header, ErrMissingETag)
+	}
+
+	value := header[1 : len(header)-1]
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == '"' || c < 0x21 || c == 0x7f {
+			return ETag{}, fmt.Errorf("%q: %w", header, ErrMissingETag)
+		}
+	}
+
+	etag.Value = value
+	return etag, nil
+}
+
+// String formats the etag for usage in a header.
+func (e ETag) String() string {
+	if e.Weak {
+		return `W/"` + e.Value + `"`
+	}
+
+	return `"` + e.Value + `"`
+}
+
+// StrongMatch reports whether both etags are equal per the strong comparison
+// function of RFC 7232 - that is, both must be strong and have the same value.
+func (e ETag) StrongMatch(other ETag) bool {
+	return !e.Weak && !other.Weak && e.Value == other.Value
+}
+
+// WeakMatch reports whether both etags are equal per the weak comparison
+// function of RFC 7232 - that is, their values are equal.
+func (e ETag) WeakMatch(other ETag) bool {
+	return e.Value == other.Value
+}
+
+// ETagFallback defines how a missing or invalid etag header is handled.
+type ETagFallback int
+
+const (
+	// ETagFallbackError reports a missing or invalid etag as ErrMissingETag.
+	ETagFallbackError ETagFallback = iota
+	// ETagFallbackLastModified derives a weak etag from the last-modified header.
+	// If that is missing or invalid too, ErrMissingETag is reported.
+	ETagFallbackLastModified
+	// ETagFallbackIgnore leaves the etag empty, without reporting an error.
+	ETagFallbackIgnore
+)
+
+// ETagOptions is the option-wrapper for defining the workings of FetchETagsForURLs.
+type ETagOptions struct {
+	Method        string
+	Fallback      ETagFallback
+	ModifyRequest func(r *http.Request) error
+}
+
+type ETagOption func(*ETagOptions)
+
+// ETagMethod sets the http method used for fetching the etag. Per default, HEAD is used.
+func ETagMethod(method string) ETagOption {
+	return func(args *ETagOptions) {
+		args.Method = method
+	}
+}
+
+// ETagFallbackMode sets how missing or invalid etags are handled.
+// Per default, ETagFallbackError is used.
+func ETagFallbackMode(fallback ETagFallback) ETagOption {
+	return func(args *ETagOptions) {
+		args.Fallback = fallback
+	}
+}
+
+// ETagInterceptor sets a hook for modifying each request prior to sending. The
+// hook is executed after the method has been set, so it may still override it.
+func ETagInterceptor(modifyRequest func(r *http.Request) error) ETagOption {
+	return func(args *ETagOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// ETagResult is the etag (and last modification date, if available) of a
+// single url, or the error that occurred while fetching it.
+type ETagResult struct {
+	URL string
+	// StatusCode is the status code of the response, or 0 if no response was received.
+	StatusCode int
+	ETag       ETag
+	// LastModified is the zero time, if the header was missing or invalid.
+	LastModified time.Time
+	Err          error
+}
+
+// FetchETagsForURLs fetches the etags for multiple urls at once.
+// The returned slice contains an ETagResult for each url, in the same order.
+func FetchETagsForURLs(ctx context.Context, executor Requester, urls []string, setters ...ETagOption) []ETagResult {
+	args := &ETagOptions{
+		Method:   http.MethodHead,
+		Fallback: ETagFallbackError,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	modifyRequest := func(r *http.Request) error {
+		r.Method = args.Method
+		if args.ModifyRequest != nil {
+			return args.ModifyRequest(r)
+		}
+
+		return nil
+	}
+
+	results := executor.AddRequestsWithInterceptor(ctx, modifyRequest, urls...)
+
+	etags := make([]ETagResult, len(urls))
+	for i, resultChan := range results {
+		etags[i] = handleETagResponse(<-resultChan, args.Fallback)
+		etags[i].URL = urls[i]
+	}
+
+	return etags
+}
+
+// CompositeValidator derives a single, stable validator for a set of resources from
+// their etags and the latest last modification date. The order of the results does
+// not matter. The validator is weak, if any of the etags is weak. If any of the
+// results carries an error, a BulkError is returned instead.
+func CompositeValidator(etags ...ETagResult) (ETag, error) {
+	var bulkErr BulkError
+	for i, etag := range etags {
+		if etag.Err != nil {
+			bulkErr = append(bulkErr, &URLError{Index: i, URL: etag.URL, StatusCode: etag.StatusCode, Err: etag.Err})
+		}
+	}
+
+	if len(bulkErr) > 0 {
+		return ETag{}, bulkErr
+	}
+
+	sorted := make([]ETagResult, len(etags))
+	copy(sorted, etags)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].URL < sorted[j].URL
+	})
+
+	var (
+		weak         bool
+		lastModified time.Time
+	)
+
+	h := sha256.New()
+	for _, etag := range sorted {
+		weak = weak || etag.ETag.Weak
+		if etag.LastModified.After(lastModified) {
+			lastModified = etag.LastModified
+		}
+
+		// The NUL byte cannot be part of urls or etags, and serves as a separator
+		h.Write([]byte(etag.URL + "\x00" + etag.ETag.String() + "\x00"))
+	}
+
+	if !lastModified.IsZero() {
+		h.Write([]byte(strconv.FormatInt(lastModified.Unix(), 10)))
+	}
+
+	return ETag{Value: hex.EncodeToString(h.Sum(nil)), Weak: weak}, nil
+}
+
+func handleETagResponse(r Result, fallback ETagFallback) ETagResult {
+	if r.Err() != nil {
+		return ETagResult{Err: r.Err()}
+	}
+
+	result := ETagResult{StatusCode: r.Res().StatusCode}
+
+	defer r.Res().Body.Close()
+	if _, err := io.Copy(ioutil.Discard, r.Res().Body); err != nil {
+		result.Err = err
+		return result
+	}
+
+	if (r.Res().StatusCode < 200 || r.Res().StatusCode > 299) && r.Res().StatusCode != http.StatusNotModified {
+		result.Err = fmt.Errorf("failed to get etag for %s: %w", r.URL(), ErrRequestFailed)
+		return result
+	}
+
+	if lastModified, err := http.ParseTime(r.Res().Header.Get("last-modified")); err == nil {
+		result.LastModified = lastModified
+	}
+
+	etag, err := ParseETag(r.Res().Header.Get("etag"))
+	if err == nil {
+		result.ETag = etag
+		return result
+	}
+
+	switch fallback {
+	case ETagFallbackIgnore:
+		return result
+	case ETagFallbackLastModified:
+		if !result.LastModified.IsZero() {
+			result.ETag = ETag{Value: strconv.FormatInt(result.LastModified.Unix(), 16), Weak: true}
+			return result
+		}
+	}
+
+	result.Err = fmt.Errorf("failed to get etag for %s: %w", r.URL(), ErrMissingETag)
+	return result
+}
@@ -0,0 +1,124 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that ParseETag correctly parses strong, weak and invalid etags.
+func Test_ParseETag(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bulk.ETag
+		invalid  bool
+	}{
+		{header: `"xyz"`, expected: bulk.ETag{Value: "xyz"}},
+		{header: `W/"xyz"`, expected: bulk.ETag{Value: "xyz", Weak: true}},
+		{header: `""`, expected: bulk.ETag{}},
+		{header: `xyz`, invalid: true},
+		{header: `"x"y"`, invalid: true},
+		{header: ``, invalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			etag, err := bulk.ParseETag(tt.header)
+			if tt.invalid {
+				if !errors.Is(err, bulk.ErrMissingETag) {
+					t.Errorf("expected missing etag error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil || etag != tt.expected {
+				t.Errorf("expected %+v, got %+v (%v)", tt.expected, etag, err)
+			}
+
+			if etag.String() != tt.header {
+				t.Errorf("expected string %s, got %s", tt.header, etag.String())
+			}
+		})
+	}
+}
+
+// Tests the strong and weak comparison functions.
+func Test_ETag_Match(t *testing.T) {
+	strong := bulk.ETag{Value: "1"}
+	weak := bulk.ETag{Value: "1", Weak: true}
+
+	if !strong.StrongMatch(strong) || strong.StrongMatch(weak) || weak.StrongMatch(weak) {
+		t.Error("strong comparison broken")
+	}
+
+	if !strong.WeakMatch(weak) || !weak.WeakMatch(weak) || weak.WeakMatch(bulk.ETag{Value: "2"}) {
+		t.Error("weak comparison broken")
+	}
+}
+
+// Tests that FetchETagsForURLs honors the fallback modes, and the composite validator is stable.
+func Test_FetchETagsForURLs(t *testing.T) {
+	// given
+	mux := http.NewServeMux()
+	mux.HandleFunc("/strong", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	})
+	mux.HandleFunc("/weak", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `W/"def"`)
+	})
+	mux.HandleFunc("/lastmod", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	urls := []string{server.URL + "/strong", server.URL + "/weak", server.URL + "/lastmod"}
+
+	// when
+	strict := bulk.FetchETagsForURLs(context.Background(), executor, urls)
+	fallback := bulk.FetchETagsForURLs(context.Background(), executor, urls, bulk.ETagFallbackMode(bulk.ETagFallbackLastModified))
+
+	// then
+	if strict[0].ETag != (bulk.ETag{Value: "abc"}) || strict[1].ETag != (bulk.ETag{Value: "def", Weak: true}) {
+		t.Errorf("etags not correctly fetched: %+v", strict)
+	}
+
+	if !errors.Is(strict[2].Err, bulk.ErrMissingETag) {
+		t.Errorf("expected missing etag error, got %v", strict[2].Err)
+	}
+
+	if _, err := bulk.CompositeValidator(strict...); !errors.Is(err, bulk.ErrMissingETag) {
+		t.Errorf("expected composite validator to fail, got %v", err)
+	}
+
+	if fallback[2].Err != nil || !fallback[2].ETag.Weak || fallback[2].ETag.Value == "" {
+		t.Errorf("expected weak etag derived from last-modified, got %+v", fallback[2])
+	}
+
+	validator, err := bulk.CompositeValidator(fallback...)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if !validator.Weak {
+		t.Error("expected weak composite validator")
+	}
+
+	reversed, err := bulk.CompositeValidator(fallback[2], fallback[1], fallback[0])
+	if err != nil || reversed != validator {
+		t.Errorf("composite validator not stable: %s vs %s (%v)", validator, reversed, err)
+	}
+
+	changed, _ := bulk.CompositeValidator(fallback[0], fallback[2])
+	if changed == validator {
+		t.Error("composite validator did not change for a different set")
+	}
+}
@@ -4,13 +4,10 @@ import (
 	bulk "github.com/kernle32dll/httpbulk-go"
 
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"net/http"
 	"testing"
 )
 
-// Demonstrates, how to quickly calculate the hash of multiple resources.
+// Demonstrates, how to quickly calculate a composite validator for multiple resources.
 func TestMultiHash(t *testing.T) {
 	executor := bulk.NewExecutor()
 
@@ -20,22 +17,20 @@ func TestMultiHash(t *testing.T) {
 		"https://www.tarent.de",
 	}
 
-	results := executor.AddRequestsWithInterceptor(context.Background(), func(r *http.Request) error {
-		// Change the request method to head - we don't need the body
-		r.Method = "HEAD"
-		return nil
-	}, urls...)
+	// HEAD requests are used per default - we don't need the body
+	etags := bulk.FetchETagsForURLs(context.Background(), executor, urls,
+		bulk.ETagFallbackMode(bulk.ETagFallbackLastModified),
+	)
 
-	h := sha256.New()
-	for _, resultChan := range results {
-		result := <-resultChan
-
-		hash := result.Res().Header.Get("etag")
-		t.Logf("%s hash %s", result.URL(), hash)
+	for _, etag := range etags {
+		t.Logf("%s etag %s", etag.URL, etag.ETag)
+	}
 
-		h.Write([]byte(hash))
+	validator, err := bulk.CompositeValidator(etags...)
+	if err != nil {
+		t.Logf("Failed to calculate validator: %s", err)
+		return
 	}
-	hash := h.Sum(nil)
 
-	t.Logf("Final hash: %s", hex.EncodeToString(hash))
+	t.Logf("Final validator: %s", validator)
 }
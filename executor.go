@@ -1,7 +1,10 @@
 package bulk
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"time"
 )
@@ -15,8 +18,18 @@ All kudos to Montana Flynn (montanaflynn)
 
 // Executor is the central bulk request maintainer.
 type Executor struct {
-	client        *http.Client
-	semaphoreChan chan struct{}
+	client         *http.Client
+	semaphoreChan  chan struct{}
+	validatorStore ValidatorStore
+	cache          *responseCache
+	flights        *flightGroup
+	metrics        Metrics
+	logger         *requestLogger
+	tracer         Tracer
+	tracker        *requestTracker
+	watchdog       *watchdog
+	readLimits     ReadLimits
+	recorder       *HARRecorder
 }
 
 // Close closes the internal channels, and makes the Executor unavailable for further usage.
@@ -24,6 +37,10 @@ func (e Executor) Close() {
 	if e.semaphoreChan != nil {
 		close(e.semaphoreChan)
 	}
+
+	if e.watchdog != nil {
+		e.watchdog.close()
+	}
 }
 
 // NewExecutor instantiates a new Executor.
@@ -44,10 +61,38 @@ func NewExecutor(setters ...Option) *Executor {
 		semaphoreChan = make(chan struct{}, args.ConcurrencyLimit)
 	}
 
-	return &Executor{
-		client:        args.Client,
-		semaphoreChan: semaphoreChan,
+	var cache *responseCache
+	if args.CacheStorage != nil {
+		cache = newResponseCache(args.CacheStorage, !args.PrivateCache)
+	}
+
+	var flights *flightGroup
+	if args.Deduplicate {
+		flights = newFlightGroup(args.DeduplicateHeaders)
+	}
+
+	executor := &Executor{
+		client:         args.Client,
+		semaphoreChan:  semaphoreChan,
+		validatorStore: args.ValidatorStore,
+		cache:          cache,
+		flights:        flights,
+		metrics:        args.Metrics,
+		tracer:         args.Tracer,
+		tracker:        newRequestTracker(),
+		readLimits:     args.ReadLimits,
+		recorder:       args.HARRecorder,
+	}
+
+	if args.Watchdog != nil {
+		executor.watchdog = newWatchdog(*args.Watchdog)
 	}
+
+	if args.LogHandler != nil {
+		executor.logger = newRequestLogger(args.LogHandler, args.LogOptions)
+	}
+
+	return executor
 }
 
 // AddRequestsWithInterceptor issues one or more urls to be called.
@@ -57,9 +102,11 @@ func (e Executor) AddRequestsWithInterceptor(
 	modifyRequest func(r *http.Request) error,
 	urls ...string,
 ) []chan Result {
+	ctx, batch := e.startBatch(ctx, "bulk batch", len(urls))
+
 	results := make([]chan Result, len(urls))
 	for i, url := range urls {
-		results[i] = e.addRequestInternal(ctx, modifyRequest, url)
+		results[i] = batch.observe(e.addRequestInternal(ctx, modifyRequest, "", url))
 	}
 
 	return results
@@ -80,9 +127,11 @@ func (e Executor) AddFutureRequestsWithInterceptor(
 	modifyRequest func(r *http.Request) error,
 	urls ...string,
 ) []*Future {
+	ctx, batch := e.startBatch(ctx, "bulk batch", len(urls))
+
 	results := make([]*Future, len(urls))
 	for i, url := range urls {
-		results[i] = &Future{resultChan: e.addRequestInternal(ctx, modifyRequest, url)}
+		results[i] = e.addFutureInternal(ctx, modifyRequest, "", url, batch)
 	}
 
 	return results
@@ -96,56 +145,310 @@ func (e Executor) AddFutureRequests(
 	return e.AddFutureRequestsWithInterceptor(ctx, nil, urls...)
 }
 
+// AddKeyedRequestsWithInterceptor issues one or more urls to be called, identified by
+// a caller chosen key. The returned map uses the same keys as the provided one, and
+// the key is also available via the Key method of each Result.
+// For each call, optional hooks for modifying the request are executed (if not nil).
+func (e Executor) AddKeyedRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls map[string]string,
+) map[string]chan Result {
+	ctx, batch := e.startBatch(ctx, "bulk batch", len(urls))
+
+	results := make(map[string]chan Result, len(urls))
+	for key, url := range urls {
+		results[key] = batch.observe(e.addRequestInternal(ctx, modifyRequest, key, url))
+	}
+
+	return results
+}
+
+// AddKeyedRequests issues one or more urls to be called, identified by a caller chosen key.
+func (e Executor) AddKeyedRequests(
+	ctx context.Context,
+	urls map[string]string,
+) map[string]chan Result {
+	return e.AddKeyedRequestsWithInterceptor(ctx, nil, urls)
+}
+
+// AddKeyedFutureRequestsWithInterceptor issues one or more urls to be called, identified
+// by a caller chosen key, and wrapped in a bulk.Future.
+// For each call, optional hooks for modifying the request are executed (if not nil).
+func (e Executor) AddKeyedFutureRequestsWithInterceptor(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	urls map[string]string,
+) map[string]*Future {
+	ctx, batch := e.startBatch(ctx, "bulk batch", len(urls))
+
+	results := make(map[string]*Future, len(urls))
+	for key, url := range urls {
+		results[key] = e.addFutureInternal(ctx, modifyRequest, key, url, batch)
+	}
+
+	return results
+}
+
+// AddKeyedFutureRequests issues one or more urls to be called, identified by a caller
+// chosen key, and wrapped in a bulk.Future.
+func (e Executor) AddKeyedFutureRequests(
+	ctx context.Context,
+	urls map[string]string,
+) map[string]*Future {
+	return e.AddKeyedFutureRequestsWithInterceptor(ctx, nil, urls)
+}
+
 func (e Executor) addRequestInternal(
 	ctx context.Context,
 	modifyRequest func(r *http.Request) error,
+	key string,
 	url string,
 ) chan Result {
 	resultChannel := make(chan Result, 1)
 
 	// start a go routine with the index and url in a closure
 	go func(url string, ctx context.Context) {
-		// If concurrency limit enabled...
-		if e.semaphoreChan != nil {
-			// this sends an empty struct into the semaphoreChan which
-			// is basically saying add one to the limit, but when the
-			// limit has been reached block until there is room
-			e.semaphoreChan <- struct{}{}
+		req, err := newRequest(url, modifyRequest)
+
+		// now we can send the result struct through the results channel
+		resultChannel <- e.dispatch(ctx, key, url, req, err)
+	}(url, ctx)
+
+	return resultChannel
+}
+
+// addFutureInternal issues the url to be called and wrapped in a bulk.Future. If the
+// context carries a memo (see WithMemo), futures are shared for identical requests.
+// The request is finished within the given batch (if any), once its result is available.
+func (e Executor) addFutureInternal(
+	ctx context.Context,
+	modifyRequest func(r *http.Request) error,
+	key string,
+	url string,
+	batch *batchSpan,
+) *Future {
+	memo := memoFromContext(ctx)
+	if memo == nil {
+		return &Future{resultChan: batch.observe(e.addRequestInternal(ctx, modifyRequest, key, url))}
+	}
+
+	// the request is built upfront, for deriving the memo key from it
+	req, err := newRequest(url, modifyRequest)
+	if err != nil {
+		resultChan := make(chan Result, 1)
+		resultChan <- Result{key: key, url: url, err: err}
+		return &Future{resultChan: batch.observe(resultChan)}
+	}
+
+	if !memoizable(req) {
+		resultChan := make(chan Result, 1)
+		go func() {
+			resultChan <- e.dispatch(ctx, key, url, req, nil)
+		}()
+
+		return &Future{resultChan: batch.observe(resultChan)}
+	}
+
+	return memo.future(ctx, req, batch, func(ctx context.Context) Result {
+		return e.dispatch(ctx, key, url, req, nil)
+	})
+}
+
+func newRequest(url string, modifyRequest func(r *http.Request) error) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if modifyRequest != nil {
+		if err := modifyRequest(req); err != nil {
+			return nil, err
 		}
+	}
+
+	return req, nil
+}
 
-		var result Result
-		if req, err := http.NewRequest(http.MethodGet, url, nil); err == nil {
-			doSend := true
-			if modifyRequest != nil {
-				if err := modifyRequest(req); err != nil {
-					result = Result{url, nil, 0, err}
-					doSend = false
-				}
-			}
-
-			if doSend {
-				start := time.Now()
-
-				// send the request and put the response in a result struct
-				// along with any error that might have occurred
-				res, err := e.client.Do(req.WithContext(ctx))
-				result = Result{url, res, time.Since(start), err}
-			}
-		} else {
-			result = Result{url, nil, 0, err}
+// postInterceptor turns the request into a POST request with the given payload,
+// before executing the (optional) given interceptor.
+func postInterceptor(
+	contentType string,
+	payload []byte,
+	modifyRequest func(r *http.Request) error,
+) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		r.Method = http.MethodPost
+		r.Header.Set("Content-Type", contentType)
+		r.ContentLength = int64(len(payload))
+		r.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(payload)), nil
 		}
 
-		// now we can send the result struct through the results channel
-		resultChannel <- result
-
-		// If concurrency limit enabled...
-		if e.semaphoreChan != nil {
-			// once we're done it's we read from the semaphoreChan which
-			// has the effect of removing one from the limit and allowing
-			// another goroutine to start
-			<-e.semaphoreChan
+		if modifyRequest != nil {
+			return modifyRequest(r)
 		}
-	}(url, ctx)
 
-	return resultChannel
+		return nil
+	}
+}
+
+// dispatch sends the request (or serves it from the cache), and returns its result.
+func (e Executor) dispatch(ctx context.Context, key, url string, req *http.Request, err error) (result Result) {
+	if err != nil {
+		return Result{key: key, url: url, err: err}
+	}
+
+	if e.tracer != nil {
+		var span Span
+		ctx, span = e.startRequestSpan(ctx, req)
+		defer func() {
+			endRequestSpan(span, result)
+		}()
+	}
+
+	req = req.WithContext(ctx)
+
+	// cache hits do not occupy a slot of the concurrency limit
+	var (
+		cached *CacheEntry
+		state  cacheState
+	)
+	if e.cache != nil {
+		cached, state = e.cache.lookup(req)
+		switch state {
+		case cacheFresh:
+			return Result{key: key, url: url, res: e.cache.response(req, cached), cacheHit: true}
+		case cacheStaleWhileRevalidate:
+			e.cache.refresh(req, e.sendLimited)
+			return Result{key: key, url: url, res: e.cache.response(req, cached), cacheHit: true}
+		}
+	}
+
+	execute := func() Result {
+		return e.execute(req, cached, state)
+	}
+
+	if e.flights != nil && e.flights.accepts(req) {
+		result = e.flights.do(ctx, e.flights.key(req), execute)
+	} else {
+		result = execute()
+	}
+
+	result.key = key
+	result.url = url
+	return result
+}
+
+// execute sends the request, while occupying a slot of the concurrency limit.
+func (e Executor) execute(req *http.Request, cached *CacheEntry, state cacheState) Result {
+	event := RequestEvent{Host: req.URL.Host, Method: req.Method}
+	if e.metrics != nil {
+		e.metrics.RequestQueued(event)
+	}
+
+	tracked := e.tracker.queue(req)
+
+	queued := time.Now()
+	e.acquire()
+	defer e.release()
+
+	start := time.Now()
+	e.tracker.start(tracked)
+
+	event.QueueWait = start.Sub(queued)
+	if e.metrics != nil {
+		e.metrics.RequestStarted(event)
+	}
+
+	// send the request and put the response in a result struct
+	// along with any error that might have occurred
+	res, revalidated, err := e.sendGuarded(req)
+
+	if e.recorder != nil {
+		res = e.recorder.record(req, res, err, start, event.QueueWait)
+	}
+
+	var cacheHit bool
+	if e.cache != nil {
+		res, cacheHit, err = e.cache.handle(req, res, err, cached, state)
+	}
+
+	result := Result{
+		res:         res,
+		dur:         time.Since(start),
+		err:         err,
+		revalidated: revalidated,
+		cacheHit:    cacheHit,
+	}
+
+	e.tracker.finish(tracked, result.failed())
+
+	if e.metrics != nil {
+		event.StatusClass = statusClass(result)
+		event.Duration = result.dur
+		event.Err = err
+		e.metrics.RequestFinished(event)
+	}
+
+	if e.logger != nil {
+		e.logger.log(req, result, event.QueueWait)
+	}
+
+	return result
+}
+
+func (e Executor) acquire() {
+	// If concurrency limit enabled...
+	if e.semaphoreChan != nil {
+		// this sends an empty struct into the semaphoreChan which
+		// is basically saying add one to the limit, but when the
+		// limit has been reached block until there is room
+		e.semaphoreChan <- struct{}{}
+	}
+}
+
+func (e Executor) release() {
+	// If concurrency limit enabled...
+	if e.semaphoreChan != nil {
+		// once we're done it's we read from the semaphoreChan which
+		// has the effect of removing one from the limit and allowing
+		// another goroutine to start
+		<-e.semaphoreChan
+	}
+}
+
+// sendLimited sends the given request like send, but occupies
+// a slot of the concurrency limit while doing so.
+func (e Executor) sendLimited(req *http.Request) (*http.Response, error) {
+	tracked := e.tracker.queue(req)
+
+	e.acquire()
+	defer e.release()
+
+	e.tracker.start(tracked)
+	res, _, err := e.send(req, e.client.Do)
+	e.tracker.finish(tracked, Result{res: res, err: err}.failed())
+
+	return res, err
+}
+
+// send issues the given request via do (usually the Do method of the http client).
+// If a validator store is configured, the request is made conditional, and a 304
+// response is replaced with the stored one - which is reported as revalidated.
+func (e Executor) send(req *http.Request, do func(req *http.Request) (*http.Response, error)) (*http.Response, bool, error) {
+	if e.validatorStore == nil || req.Method != http.MethodGet {
+		res, err := do(req)
+		return res, false, err
+	}
+
+	stored := applyValidators(e.validatorStore, req)
+
+	res, err := do(req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return handleValidators(e.validatorStore, req, res, stored)
 }
@@ -0,0 +1,79 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that AddKeyedRequests returns the results under the keys they were issued with.
+func Test_Executor_AddKeyedRequests(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	urls := map[string]string{
+		"alice": server.URL + "/users/alice",
+		"bob":   server.URL + "/users/bob",
+	}
+
+	// when
+	results := executor.AddKeyedRequests(context.Background(), urls)
+
+	// then
+	if len(results) != len(urls) {
+		t.Fatalf("expected %d results, got %d", len(urls), len(results))
+	}
+
+	for key, resultChan := range results {
+		result := <-resultChan
+		if result.Err() != nil {
+			t.Fatalf("unexpected error %s", result.Err())
+		}
+
+		if result.Key() != key {
+			t.Errorf("expected key %q, got %q", key, result.Key())
+		}
+
+		if result.URL() != urls[key] {
+			t.Errorf("expected url %q for key %q, got %q", urls[key], key, result.URL())
+		}
+
+		if path := result.Res().Header.Get("X-Path"); path != "/users/"+key {
+			t.Errorf("result for key %q belongs to path %q", key, path)
+		}
+	}
+}
+
+// Tests that AddKeyedFutureRequests returns the futures under the keys they were issued with.
+func Test_Executor_AddKeyedFutureRequests(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	futures := executor.AddKeyedFutureRequests(context.Background(), map[string]string{
+		"first":  server.URL + "/1",
+		"second": server.URL + "/2",
+	})
+
+	// then
+	if result := futures["first"].Get(); result.Key() != "first" || result.URL() != server.URL+"/1" {
+		t.Errorf("future for key first resolved to %q (%s)", result.Key(), result.URL())
+	}
+
+	if result := futures["second"].Get(); result.Key() != "second" || result.URL() != server.URL+"/2" {
+		t.Errorf("future for key second resolved to %q (%s)", result.Key(), result.URL())
+	}
+}
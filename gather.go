@@ -0,0 +1,237 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+var (
+	ErrInvalidTarget = errors.New("gather target must be a non-nil pointer to a struct")
+	ErrInvalidTag    = errors.New("invalid bulk struct tag")
+)
+
+// GatherOptions is the option-wrapper for defining the workings of Gather.
+type GatherOptions struct {
+	BaseURL       string
+	Params        map[string]string
+	ModifyRequest func(r *http.Request) error
+}
+
+type GatherOption func(*GatherOptions)
+
+// GatherBaseURL sets the url, which relative urls of struct tags are resolved against.
+func GatherBaseURL(baseURL string) GatherOption {
+	return func(args *GatherOptions) {
+		args.BaseURL = baseURL
+	}
+}
+
+// GatherParam sets the value for a {placeholder} used in the urls of struct tags.
+// The value is path escaped before being inserted.
+func GatherParam(name, value string) GatherOption {
+	return func(args *GatherOptions) {
+		args.Params[name] = value
+	}
+}
+
+// GatherInterceptor sets a hook for modifying each request prior to sending.
+func GatherInterceptor(modifyRequest func(r *http.Request) error) GatherOption {
+	return func(args *GatherOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// FieldError describes the failure of fetching or decoding a single field via Gather.
+type FieldError struct {
+	Field string
+	URL   string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("failed to gather field %s: %s", e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+type gatherField struct {
+	index    int
+	name     string
+	url      string
+	optional bool
+}
+
+// Gather fetches all fields of the struct pointed to by target, which carry a bulk
+// struct tag, in parallel and decodes the (json) responses into the respective field.
+//
+// The tag has the form `bulk:"url=/users/{id}"`, where {placeholders} are replaced
+// by values provided via GatherParam, and relative urls are resolved against the
+// url provided via GatherBaseURL. Appending ",optional" to the tag marks the field
+// as optional - failing to gather an optional field does not fail the whole aggregate.
+// Commas within the url are kept, as long as they do not precede a known option.
+//
+// A field is only assigned, if its response was decoded successfully - so fields
+// failing to gather (optional or not) are left untouched.
+//
+// Errors of non-optional fields are returned as a BulkError, after all requests have
+// finished. Each of its URLErrors is keyed by the field name, and wraps a FieldError. Malformed tags or targets are reported before any request is issued.
+func Gather(ctx context.Context, executor Requester, target interface{}, setters ...GatherOption) error {
+	args := &GatherOptions{
+		Params: map[string]string{},
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return ErrInvalidTarget
+	}
+	value = value.Elem()
+
+	fields, err := parseGatherFields(value.Type(), args)
+	if err != nil {
+		return err
+	}
+
+	urls := make(map[string]string, len(fields))
+	for _, field := range fields {
+		urls[field.name] = field.url
+	}
+
+	results := executor.AddKeyedRequestsWithInterceptor(ctx, args.ModifyRequest, urls)
+
+	var bulkErr BulkError
+	for i, field := range fields {
+		// decoded into a fresh value, so a partial decode does not leave the field half-filled
+		fieldValue := value.Field(field.index)
+		decoded := reflect.New(fieldValue.Type())
+
+		result := <-results[field.name]
+		err := decodeGatherResult(result, decoded.Interface())
+		if err == nil {
+			fieldValue.Set(decoded.Elem())
+		} else if !field.optional {
+			bulkErr = append(bulkErr, newURLError(i, result, &FieldError{Field: field.name, URL: field.url, Err: err}))
+		}
+	}
+
+	if len(bulkErr) > 0 {
+		return bulkErr
+	}
+
+	return nil
+}
+
+func parseGatherFields(typ reflect.Type, args *GatherOptions) ([]gatherField, error) {
+	var base *url.URL
+	if args.BaseURL != "" {
+		parsed, err := url.Parse(args.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		base = parsed
+	}
+
+	var fields []gatherField
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+
+		tag, ok := structField.Tag.Lookup("bulk")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if structField.PkgPath != "" {
+			return nil, fmt.Errorf("field %s is unexported: %w", structField.Name, ErrInvalidTag)
+		}
+
+		field := gatherField{index: i, name: structField.Name}
+		inURL := false
+		for _, part := range strings.Split(tag, ",") {
+			switch {
+			case strings.HasPrefix(part, "url="):
+				field.url = strings.TrimPrefix(part, "url=")
+				inURL = true
+			case part == "optional":
+				field.optional = true
+				inURL = false
+			case inURL:
+				// the comma was part of the url (e.g. "?ids=1,2")
+				field.url += "," + part
+			default:
+				return nil, fmt.Errorf("unknown tag option %q for field %s: %w", part, structField.Name, ErrInvalidTag)
+			}
+		}
+
+		if field.url == "" {
+			return nil, fmt.Errorf("missing url for field %s: %w", structField.Name, ErrInvalidTag)
+		}
+
+		expanded, err := expandGatherURL(field.url, args.Params)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+		}
+
+		if base != nil {
+			ref, err := url.Parse(expanded)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", structField.Name, err)
+			}
+			expanded = base.ResolveReference(ref).String()
+		}
+
+		field.url = expanded
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func expandGatherURL(rawURL string, params map[string]string) (string, error) {
+	var builder strings.Builder
+	for {
+		start := strings.IndexByte(rawURL, '{')
+		if start < 0 {
+			builder.WriteString(rawURL)
+			return builder.String(), nil
+		}
+
+		end := strings.IndexByte(rawURL[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated placeholder in %q: %w", rawURL, ErrInvalidTag)
+		}
+
+		name := rawURL[start+1 : start+end]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("no value for placeholder {%s}: %w", name, ErrInvalidTag)
+		}
+
+		builder.WriteString(rawURL[:start])
+		builder.WriteString(url.PathEscape(value))
+		rawURL = rawURL[start+end+1:]
+	}
+}
+
+func decodeGatherResult(result Result, target interface{}) error {
+	if result.Err() != nil {
+		return result.Err()
+	}
+
+	if result.Res().StatusCode < 200 || result.Res().StatusCode > 299 {
+		result.Res().Body.Close()
+		return fmt.Errorf("unexpected status %d: %w", result.Res().StatusCode, ErrRequestFailed)
+	}
+
+	return result.UnmarshalResponse(target)
+}
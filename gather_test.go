@@ -0,0 +1,147 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type gatherUser struct {
+	Name string `json:"name"`
+}
+
+type gatherOrg struct {
+	Title string `json:"title"`
+}
+
+func newGatherServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"alice"}`))
+	})
+	mux.HandleFunc("/orgs/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"title":"acme"}`))
+	})
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name":"` + r.URL.Query().Get("ids") + `"}]`))
+	})
+	mux.HandleFunc("/broken/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name":"bob","title":`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// Tests that Gather fetches and decodes all tagged fields.
+func Test_Gather(t *testing.T) {
+	// given
+	server := newGatherServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	var target struct {
+		User    gatherUser   `bulk:"url=/users/{id}"`
+		Org     *gatherOrg   `bulk:"url=/orgs/{id}"`
+		Users   []gatherUser `bulk:"url=/users?ids=1,2,optional"`
+		Ignored string
+	}
+
+	// when
+	err := bulk.Gather(context.Background(), executor, &target,
+		bulk.GatherBaseURL(server.URL),
+		bulk.GatherParam("id", "42"),
+	)
+
+	// then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if target.User.Name != "alice" {
+		t.Errorf("user not gathered, got %+v", target.User)
+	}
+
+	if target.Org == nil || target.Org.Title != "acme" {
+		t.Errorf("org not gathered, got %+v", target.Org)
+	}
+
+	if len(target.Users) != 1 || target.Users[0].Name != "1,2" {
+		t.Errorf("users not gathered with comma in url, got %+v", target.Users)
+	}
+}
+
+// Tests that Gather collects errors of required fields, but ignores optional ones.
+func Test_Gather_FieldErrors(t *testing.T) {
+	// given
+	server := newGatherServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	var target struct {
+		User     gatherUser `bulk:"url=/users/{id}"`
+		Missing  gatherUser `bulk:"url=/missing/{id}"`
+		Optional gatherUser `bulk:"url=/optional/{id},optional"`
+		Broken   gatherUser `bulk:"url=/broken/{id},optional"`
+	}
+	target.Broken.Name = "untouched"
+
+	// when
+	err := bulk.Gather(context.Background(), executor, &target,
+		bulk.GatherBaseURL(server.URL),
+		bulk.GatherParam("id", "42"),
+	)
+
+	// then
+	var bulkErr bulk.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("expected bulk error, got %v", err)
+	}
+
+	if len(bulkErr) != 1 || bulkErr[0].Key != "Missing" || bulkErr[0].StatusCode != http.StatusNotFound {
+		t.Errorf("expected exactly the Missing field to fail, got %s", bulkErr)
+	}
+
+	if !errors.Is(err, bulk.ErrRequestFailed) || bulkErr[0].Kind() != bulk.ErrorKindStatus {
+		t.Errorf("expected request failed error, got %s", bulkErr[0].Err)
+	}
+
+	var fieldErr *bulk.FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "Missing" {
+		t.Errorf("expected field error of Missing, got %v", fieldErr)
+	}
+
+	if target.Broken.Name != "untouched" {
+		t.Errorf("expected partially decoded field to be untouched, got %+v", target.Broken)
+	}
+
+	if target.User.Name != "alice" {
+		t.Errorf("user not gathered, got %+v", target.User)
+	}
+}
+
+// Tests that Gather rejects malformed tags before issuing any request.
+func Test_Gather_InvalidTag(t *testing.T) {
+	// given
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	var target struct {
+		User gatherUser `bulk:"url=/users/{id}"`
+	}
+
+	// when
+	err := bulk.Gather(context.Background(), executor, &target)
+
+	// then
+	if !errors.Is(err, bulk.ErrInvalidTag) {
+		t.Errorf("expected invalid tag error, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrInvalidNode       = errors.New("invalid graph node")
+	ErrDuplicateNode     = errors.New("duplicate graph node")
+	ErrUnknownDependency = errors.New("unknown graph dependency")
+	ErrGraphCycle        = errors.New("graph contains a cycle")
+	ErrDependencyFailed  = errors.New("graph dependency failed")
+)
+
+// Node is a single request within a dependency graph, as executed by ExecuteGraph.
+type Node struct {
+	// Name uniquely identifies the node within its graph, and is used
+	// as the key of its Result.
+	Name string
+
+	// DependsOn lists the names of all nodes, which must have finished
+	// successfully before this node is started.
+	DependsOn []string
+
+	// Build returns the url to request for this node. It receives the futures
+	// of all nodes listed in DependsOn (keyed by name), which are already done.
+	Build func(upstream map[string]*Future) (string, error)
+
+	// ModifyRequest is an optional hook for modifying the request prior to sending.
+	ModifyRequest func(r *http.Request) error
+}
+
+// DependencyError is the error of a node, which was not executed
+// because one of its dependencies failed.
+type DependencyError struct {
+	Node       string
+	Dependency string
+	Err        error
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("node %s not executed, as dependency %s failed: %s", e.Node, e.Dependency, e.Err)
+}
+
+// Is reports ErrDependencyFailed as well as the error of the failed dependency.
+func (e *DependencyError) Is(target error) bool {
+	return target == ErrDependencyFailed
+}
+
+// Unwrap returns the error of the failed dependency.
+func (e *DependencyError) Unwrap() error {
+	return e.Err
+}
+
+// ExecuteGraph issues all nodes of a dependency graph to be called. Each node is started
+// as soon as all of its dependencies finished successfully. If a dependency failed (either
+// because of a request error, a non 2xx status code, or an error returned by Build), the
+// node is not executed, and its Result carries a DependencyError instead - which in turn
+// cascades to all of its dependents.
+//
+// The graph is validated beforehand, and an error is returned for nodes without a name
+// or Build function, duplicate names, unknown dependencies and cycles. Otherwise, the
+// returned map contains a Future for each node, keyed by name.
+func ExecuteGraph(ctx context.Context, executor Requester, nodes ...Node) (map[string]*Future, error) {
+	byName := make(map[string]Node, len(nodes))
+	for i, node := range nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("node #%d: missing name: %w", i, ErrInvalidNode)
+		}
+
+		if node.Build == nil {
+			return nil, fmt.Errorf("%s: missing build function: %w", node.Name, ErrInvalidNode)
+		}
+
+		if _, ok := byName[node.Name]; ok {
+			return nil, fmt.Errorf("%s: %w", node.Name, ErrDuplicateNode)
+		}
+		byName[node.Name] = node
+	}
+
+	if err := validateGraph(byName); err != nil {
+		return nil, err
+	}
+
+	futures := make(map[string]*Future, len(nodes))
+	for _, node := range nodes {
+		futures[node.Name] = &Future{resultChan: make(chan Result, 1)}
+	}
+
+	for _, node := range nodes {
+		go executeNode(ctx, executor, node, futures)
+	}
+
+	return futures, nil
+}
+
+func executeNode(ctx context.Context, executor Requester, node Node, futures map[string]*Future) {
+	resultChan := futures[node.Name].resultChan
+
+	upstream := make(map[string]*Future, len(node.DependsOn))
+	for _, dependency := range node.DependsOn {
+		future := futures[dependency]
+		if err := dependencyErr(future.Get()); err != nil {
+			resultChan <- Result{
+				key: node.Name,
+				err: &DependencyError{Node: node.Name, Dependency: dependency, Err: err},
+			}
+			return
+		}
+
+		upstream[dependency] = future
+	}
+
+	url, err := node.Build(upstream)
+	if err != nil {
+		resultChan <- Result{key: node.Name, url: url, err: err}
+		return
+	}
+
+	resultChan <- <-addRequest(ctx, executor, node.ModifyRequest, node.Name, url)
+}
+
+// dependencyErr returns the error of a finished dependency. Just like with WaitAll,
+// a non 2xx status code counts as failure.
+func dependencyErr(result Result) error {
+	if err := result.Err(); err != nil {
+		return err
+	}
+
+	if status := result.res.StatusCode; status < 200 || status > 299 {
+		return fmt.Errorf("unexpected status %d: %w", status, ErrRequestFailed)
+	}
+
+	return nil
+}
+
+func validateGraph(nodes map[string]Node) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[string]int, len(nodes))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%s: %w", name, ErrGraphCycle)
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		for _, dependency := range nodes[name].DependsOn {
+			if _, ok := nodes[dependency]; !ok {
+				return fmt.Errorf("%s depends on %s: %w", name, dependency, ErrUnknownDependency)
+			}
+
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		return nil
+	}
+
+	for name := range nodes {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
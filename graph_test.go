@@ -0,0 +1,220 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that ExecuteGraph builds dependent requests from the results of their dependencies.
+func Test_ExecuteGraph(t *testing.T) {
+	// given
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users/alice", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"org":"acme"}`))
+	})
+	mux.HandleFunc("/orgs/acme", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"title":"ACME Corp."}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	futures, err := bulk.ExecuteGraph(context.Background(), executor,
+		bulk.Node{
+			Name:      "org",
+			DependsOn: []string{"user"},
+			Build: func(upstream map[string]*bulk.Future) (string, error) {
+				var user struct {
+					Org string `json:"org"`
+				}
+				if err := upstream["user"].UnmarshalResponse(&user); err != nil {
+					return "", err
+				}
+
+				return server.URL + "/orgs/" + user.Org, nil
+			},
+		},
+		bulk.Node{
+			Name: "user",
+			Build: func(map[string]*bulk.Future) (string, error) {
+				return server.URL + "/users/alice", nil
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	var org struct {
+		Title string `json:"title"`
+	}
+	if err := futures["org"].UnmarshalResponse(&org); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if org.Title != "ACME Corp." {
+		t.Errorf("dependent node resolved to unexpected org %+v", org)
+	}
+
+	if futures["org"].Get().Key() != "org" {
+		t.Errorf("unexpected key %q", futures["org"].Get().Key())
+	}
+}
+
+// Tests that a failing node cascades its error to all (transitive) dependents.
+func Test_ExecuteGraph_Cascade(t *testing.T) {
+	// given
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	buildErr := errors.New("expected error")
+
+	// when
+	futures, err := bulk.ExecuteGraph(context.Background(), executor,
+		bulk.Node{
+			Name: "a",
+			Build: func(map[string]*bulk.Future) (string, error) {
+				return "", buildErr
+			},
+		},
+		bulk.Node{
+			Name:      "b",
+			DependsOn: []string{"a"},
+			Build: func(map[string]*bulk.Future) (string, error) {
+				t.Error("node b must not be built")
+				return "", nil
+			},
+		},
+		bulk.Node{
+			Name:      "c",
+			DependsOn: []string{"b"},
+			Build: func(map[string]*bulk.Future) (string, error) {
+				t.Error("node c must not be built")
+				return "", nil
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	if err := futures["a"].Get().Err(); !errors.Is(err, buildErr) {
+		t.Errorf("expected build error, got %v", err)
+	}
+
+	cErr := futures["c"].Get().Err()
+	if !errors.Is(cErr, bulk.ErrDependencyFailed) || !errors.Is(cErr, buildErr) {
+		t.Errorf("expected cascaded dependency error, got %v", cErr)
+	}
+
+	var dependencyErr *bulk.DependencyError
+	if !errors.As(cErr, &dependencyErr) || dependencyErr.Dependency != "b" {
+		t.Errorf("expected dependency error naming b, got %v", cErr)
+	}
+}
+
+// Tests that a dependency responding with a non 2xx status code cascades to its dependents.
+func Test_ExecuteGraph_CascadeStatus(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	futures, err := bulk.ExecuteGraph(context.Background(), executor,
+		bulk.Node{
+			Name: "a",
+			Build: func(map[string]*bulk.Future) (string, error) {
+				return server.URL, nil
+			},
+		},
+		bulk.Node{
+			Name:      "b",
+			DependsOn: []string{"a"},
+			Build: func(map[string]*bulk.Future) (string, error) {
+				t.Error("node b must not be built")
+				return "", nil
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	bErr := futures["b"].Get().Err()
+	if !errors.Is(bErr, bulk.ErrDependencyFailed) || !errors.Is(bErr, bulk.ErrRequestFailed) {
+		t.Errorf("expected cascaded request failed error, got %v", bErr)
+	}
+
+	if result := futures["a"].Get(); result.Err() == nil {
+		result.Res().Body.Close()
+	}
+}
+
+// Tests that ExecuteGraph rejects invalid graphs.
+func Test_ExecuteGraph_Invalid(t *testing.T) {
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	build := func(map[string]*bulk.Future) (string, error) {
+		return "", nil
+	}
+
+	tests := []struct {
+		name     string
+		nodes    []bulk.Node
+		expected error
+	}{
+		{
+			name:     "unnamed",
+			nodes:    []bulk.Node{{Build: build}},
+			expected: bulk.ErrInvalidNode,
+		},
+		{
+			name:     "without build",
+			nodes:    []bulk.Node{{Name: "a"}},
+			expected: bulk.ErrInvalidNode,
+		},
+		{
+			name:     "duplicate",
+			nodes:    []bulk.Node{{Name: "a", Build: build}, {Name: "a", Build: build}},
+			expected: bulk.ErrDuplicateNode,
+		},
+		{
+			name:     "unknown",
+			nodes:    []bulk.Node{{Name: "a", DependsOn: []string{"b"}, Build: build}},
+			expected: bulk.ErrUnknownDependency,
+		},
+		{
+			name: "cycle",
+			nodes: []bulk.Node{
+				{Name: "a", DependsOn: []string{"b"}, Build: build},
+				{Name: "b", DependsOn: []string{"a"}, Build: build},
+			},
+			expected: bulk.ErrGraphCycle,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := bulk.ExecuteGraph(context.Background(), executor, tt.nodes...); !errors.Is(err, tt.expected) {
+				t.Errorf("expected %s, got %v", tt.expected, err)
+			}
+		})
+	}
+}
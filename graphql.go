@@ -0,0 +1,296 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrGraphQLNoResponse = errors.New("graphql response missing")
+)
+
+// GraphQLOperation is a single GraphQL operation. After execution, the data is
+// decoded into Data (if not nil), and Err carries the error of the operation, if any.
+//
+// Note, that GraphQL servers may respond with partial data alongside errors. In
+// that case, Data is decoded nevertheless, and Err is a GraphQLErrors.
+type GraphQLOperation struct {
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	Data          interface{}
+
+	Err error
+}
+
+// GraphQLLocation is the location of an error within the query.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a single entry of the errors array of a GraphQL response.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *GraphQLError) Error() string {
+	if len(e.Path) == 0 {
+		return "graphql: " + e.Message
+	}
+
+	path := make([]string, len(e.Path))
+	for i, segment := range e.Path {
+		path[i] = fmt.Sprint(segment)
+	}
+
+	return fmt.Sprintf("graphql: %s (at %s)", e.Message, strings.Join(path, "."))
+}
+
+// GraphQLErrors is the errors array of a GraphQL response.
+type GraphQLErrors []*GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// As finds the first contained error that matches target, and if so,
+// sets target to that error value and returns true.
+func (e GraphQLErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors GraphQLErrors   `json:"errors"`
+}
+
+// GraphQLClientOptions is the option-wrapper for defining the workings of a GraphQLClient.
+type GraphQLClientOptions struct {
+	ModifyRequest func(r *http.Request) error
+}
+
+type GraphQLClientOption func(*GraphQLClientOptions)
+
+// GraphQLInterceptor sets a hook for modifying each request prior to sending.
+func GraphQLInterceptor(modifyRequest func(r *http.Request) error) GraphQLClientOption {
+	return func(args *GraphQLClientOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// GraphQLClient submits GraphQL operations to a single endpoint via an Executor.
+type GraphQLClient struct {
+	executor Requester
+	url      string
+	args     *GraphQLClientOptions
+}
+
+// NewGraphQLClient instantiates a new GraphQLClient for the given endpoint.
+func NewGraphQLClient(executor Requester, url string, setters ...GraphQLClientOption) *GraphQLClient {
+	args := &GraphQLClientOptions{}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	return &GraphQLClient{executor: executor, url: url, args: args}
+}
+
+// Query submits a single operation, and decodes its data into data (if not nil).
+func (c *GraphQLClient) Query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	operation := &GraphQLOperation{Query: query, Variables: variables, Data: data}
+	c.FanOut(ctx, operation)
+
+	return operation.Err
+}
+
+// FanOut submits each operation as an individual request, in parallel. The error of each
+// operation is set on the operation itself. If any operation failed, a BulkError is
+// returned in addition.
+func (c *GraphQLClient) FanOut(ctx context.Context, operations ...*GraphQLOperation) error {
+	done := make(chan struct{}, len(operations))
+	for _, operation := range operations {
+		go func(operation *GraphQLOperation) {
+			defer func() { done <- struct{}{} }()
+
+			responses, err := c.post(ctx, newGraphQLRequest(operation), false)
+			if err != nil {
+				operation.Err = err
+				return
+			}
+
+			operation.Err = decodeGraphQLResponse(responses[0], operation.Data)
+		}(operation)
+	}
+
+	for range operations {
+		<-done
+	}
+
+	return c.collectErrors(operations)
+}
+
+// Batch submits all operations as a single array within one request, for servers
+// which support array batching. The error of each operation is set on the operation
+// itself. If any operation failed, a BulkError is returned in addition.
+func (c *GraphQLClient) Batch(ctx context.Context, operations ...*GraphQLOperation) error {
+	if len(operations) == 0 {
+		return nil
+	}
+
+	requests := make([]graphQLRequest, len(operations))
+	for i, operation := range operations {
+		requests[i] = newGraphQLRequest(operation)
+	}
+
+	ctx, batch := startRequesterBatch(ctx, c.executor, "graphql batch", len(operations))
+	responses, err := c.post(ctx, requests, true)
+	if err == nil && len(responses) != len(operations) {
+		err = fmt.Errorf("expected %d responses, got %d: %w", len(operations), len(responses), ErrGraphQLNoResponse)
+	}
+	batch.end(err)
+
+	for i, operation := range operations {
+		if err != nil {
+			operation.Err = err
+			continue
+		}
+
+		// batched responses are in the same order as the operations
+		operation.Err = decodeGraphQLResponse(responses[i], operation.Data)
+	}
+
+	return c.collectErrors(operations)
+}
+
+func newGraphQLRequest(operation *GraphQLOperation) graphQLRequest {
+	return graphQLRequest{
+		Query:         operation.Query,
+		OperationName: operation.OperationName,
+		Variables:     operation.Variables,
+	}
+}
+
+func (c *GraphQLClient) post(ctx context.Context, payload interface{}, batch bool) ([]graphQLResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	modifyRequest := postInterceptor("application/json", body, func(r *http.Request) error {
+		r.Header.Set("Accept", "application/json")
+		if c.args.ModifyRequest != nil {
+			return c.args.ModifyRequest(r)
+		}
+
+		return nil
+	})
+
+	result := <-addRequest(ctx, c.executor, modifyRequest, "", c.url)
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	defer result.Res().Body.Close()
+	responseBody, err := ioutil.ReadAll(result.Res().Body)
+	if err != nil {
+		return nil, err
+	}
+
+	statusErr := func() error {
+		return fmt.Errorf("unexpected status %d: %w", result.Res().StatusCode, ErrRequestFailed)
+	}
+
+	// servers may respond with error status codes, while still providing errors in the body
+	responseBody = bytes.TrimSpace(responseBody)
+	if batch && len(responseBody) > 0 && responseBody[0] == '[' {
+		var responses []graphQLResponse
+		if err := json.Unmarshal(responseBody, &responses); err != nil {
+			if result.Res().StatusCode < 200 || result.Res().StatusCode > 299 {
+				return nil, statusErr()
+			}
+			return nil, err
+		}
+
+		return responses, nil
+	}
+
+	var response graphQLResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil || (response.Data == nil && len(response.Errors) == 0) {
+		if result.Res().StatusCode < 200 || result.Res().StatusCode > 299 {
+			return nil, statusErr()
+		}
+		if err == nil {
+			err = ErrGraphQLNoResponse
+		}
+		return nil, err
+	}
+
+	// a single response to a batch is a request-level error, which applies to all operations
+	if batch {
+		if len(response.Errors) > 0 {
+			return nil, response.Errors
+		}
+
+		return nil, fmt.Errorf("expected array of responses: %w", ErrGraphQLNoResponse)
+	}
+
+	return []graphQLResponse{response}, nil
+}
+
+func (c *GraphQLClient) collectErrors(operations []*GraphQLOperation) error {
+	var bulkErr BulkError
+	for i, operation := range operations {
+		if operation.Err != nil {
+			bulkErr = append(bulkErr, &URLError{Index: i, URL: c.url, Err: operation.Err})
+		}
+	}
+
+	if len(bulkErr) > 0 {
+		return bulkErr
+	}
+
+	return nil
+}
+
+// decodeGraphQLResponse decodes the (possibly partial) data, and returns the errors of the response.
+func decodeGraphQLResponse(response graphQLResponse, target interface{}) error {
+	if target != nil && len(response.Data) > 0 && string(response.Data) != "null" {
+		if err := json.Unmarshal(response.Data, target); err != nil {
+			return err
+		}
+	}
+
+	if len(response.Errors) > 0 {
+		return response.Errors
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testGraphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func handleTestGraphQL(request testGraphQLRequest) map[string]interface{} {
+	name, _ := request.Variables["name"].(string)
+	if name == "partial" {
+		return map[string]interface{}{
+			"data": map[string]interface{}{"user": map[string]interface{}{"name": name, "org": nil}},
+			"errors": []interface{}{
+				map[string]interface{}{"message": "org not accessible", "path": []interface{}{"user", "org"}},
+			},
+		}
+	}
+
+	return map[string]interface{}{
+		"data": map[string]interface{}{"user": map[string]interface{}{"name": name}},
+	}
+}
+
+func newGraphQLServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) > 0 && body[0] == '[' {
+			var batch []testGraphQLRequest
+			json.Unmarshal(body, &batch)
+
+			responses := make([]interface{}, len(batch))
+			for i, request := range batch {
+				responses[i] = handleTestGraphQL(request)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var request testGraphQLRequest
+		json.Unmarshal(body, &request)
+		json.NewEncoder(w).Encode(handleTestGraphQL(request))
+	}))
+}
+
+type graphQLUserData struct {
+	User struct {
+		Name string  `json:"name"`
+		Org  *string `json:"org"`
+	} `json:"user"`
+}
+
+// Tests that partial errors surface alongside the decoded data.
+func Test_GraphQLClient_PartialErrors(t *testing.T) {
+	// given
+	server := newGraphQLServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	client := bulk.NewGraphQLClient(executor, server.URL)
+
+	// when
+	var data graphQLUserData
+	err := client.Query(context.Background(), "query($name: String!) { user(name: $name) { name org } }",
+		map[string]interface{}{"name": "partial"}, &data)
+
+	// then
+	if data.User.Name != "partial" {
+		t.Errorf("partial data not decoded, got %+v", data)
+	}
+
+	var gqlErrs bulk.GraphQLErrors
+	if !errors.As(err, &gqlErrs) || len(gqlErrs) != 1 || gqlErrs[0].Message != "org not accessible" {
+		t.Fatalf("expected graphql errors, got %v", err)
+	}
+
+	if len(gqlErrs[0].Path) != 2 || gqlErrs[0].Path[1] != "org" {
+		t.Errorf("unexpected error path %v", gqlErrs[0].Path)
+	}
+}
+
+// Tests that batched operations are decoded in order.
+func Test_GraphQLClient_Batch(t *testing.T) {
+	// given
+	server := newGraphQLServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	client := bulk.NewGraphQLClient(executor, server.URL)
+
+	var alice, bob graphQLUserData
+	operations := []*bulk.GraphQLOperation{
+		{Query: "query", Variables: map[string]interface{}{"name": "alice"}, Data: &alice},
+		{Query: "query", Variables: map[string]interface{}{"name": "bob"}, Data: &bob},
+	}
+
+	for _, submit := range []func(context.Context, ...*bulk.GraphQLOperation) error{client.Batch, client.FanOut} {
+		alice, bob = graphQLUserData{}, graphQLUserData{}
+
+		// when
+		err := submit(context.Background(), operations...)
+
+		// then
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+
+		if alice.User.Name != "alice" || bob.User.Name != "bob" {
+			t.Errorf("unexpected data %+v and %+v", alice, bob)
+		}
+	}
+}
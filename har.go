@@ -0,0 +1,549 @@
+package bulk
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const harRedacted = "[REDACTED]"
+
+// HARRecorderOptions is the option-wrapper for defining the workings of a HARRecorder.
+type HARRecorderOptions struct {
+	MaxBodySize       int
+	RedactHeaders     []string
+	RedactQueryParams []string
+}
+
+type HARRecorderOption func(*HARRecorderOptions)
+
+// HARMaxBodySize sets the maximum number of bytes captured per request and response
+// body. Longer bodies are truncated, and 0 disables capturing bodies altogether.
+// Per default, up to 64 KiB are captured.
+func HARMaxBodySize(size int) HARRecorderOption {
+	return func(args *HARRecorderOptions) {
+		args.MaxBodySize = size
+	}
+}
+
+// HARRedactHeaders adds (case-insensitive) names of request and response headers,
+// whose values are redacted. Per default, the Authorization, Proxy-Authorization,
+// Cookie and Set-Cookie headers are redacted.
+func HARRedactHeaders(names ...string) HARRecorderOption {
+	return func(args *HARRecorderOptions) {
+		args.RedactHeaders = append(args.RedactHeaders, names...)
+	}
+}
+
+// HARRedactQueryParams adds names of query parameters, whose values are redacted - in the
+// request url, redirect locations and recorded errors alike.
+// Per default, no query parameters are redacted.
+func HARRedactQueryParams(names ...string) HARRecorderOption {
+	return func(args *HARRecorderOptions) {
+		args.RedactQueryParams = append(args.RedactQueryParams, names...)
+	}
+}
+
+// HARRecorder records the traffic of an Executor (see RecordHAR), and exports it in the
+// HTTP Archive (HAR 1.2) format. Only requests actually sent upstream are recorded.
+type HARRecorder struct {
+	args          *HARRecorderOptions
+	redactHeaders map[string]bool
+	redactParams  map[string]bool
+
+	mutex   sync.Mutex
+	records []*harRecord
+}
+
+// NewHARRecorder instantiates a new HARRecorder.
+func NewHARRecorder(setters ...HARRecorderOption) *HARRecorder {
+	// Default Options
+	args := &HARRecorderOptions{
+		MaxBodySize:   64 * 1024,
+		RedactHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	recorder := &HARRecorder{
+		args:          args,
+		redactHeaders: map[string]bool{},
+		redactParams:  map[string]bool{},
+	}
+
+	for _, name := range args.RedactHeaders {
+		recorder.redactHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+
+	for _, name := range args.RedactQueryParams {
+		recorder.redactParams[name] = true
+	}
+
+	return recorder
+}
+
+// Len returns the number of recorded entries.
+func (r *HARRecorder) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.records)
+}
+
+// Reset discards all recorded entries.
+func (r *HARRecorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.records = nil
+}
+
+// WriteTo writes all recorded entries as HAR 1.2 JSON to the given writer.
+func (r *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	data, err := r.MarshalJSON()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// MarshalJSON returns all recorded entries as HAR 1.2 JSON.
+func (r *HARRecorder) MarshalJSON() ([]byte, error) {
+	r.mutex.Lock()
+	entries := make([]harEntry, len(r.records))
+	for i, record := range r.records {
+		entries[i] = r.entry(record)
+	}
+	r.mutex.Unlock()
+
+	return json.Marshal(harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "httpbulk-go"},
+		Entries: entries,
+	}})
+}
+
+// harRecord is the internal representation of an entry, which may still be
+// updated while the response body is read.
+type harRecord struct {
+	started   time.Time
+	queueWait time.Duration
+	wait      time.Duration
+	receive   time.Duration
+
+	method      string
+	url         *url.URL
+	proto       string
+	reqHeader   http.Header
+	reqMimeType string
+	reqBody     []byte
+	reqBodySize int64
+
+	status    int
+	resProto  string
+	resHeader http.Header
+	resBody   bytes.Buffer
+	resSize   int64
+	truncated bool
+
+	err error
+}
+
+// record adds an entry for the given request and its outcome, which was sent at start
+// after waiting for queueWait. If a response was received, a copy of it is returned,
+// whose body is captured while being read.
+func (r *HARRecorder) record(
+	req *http.Request,
+	res *http.Response,
+	err error,
+	start time.Time,
+	queueWait time.Duration,
+) *http.Response {
+	record := &harRecord{
+		started:     start.Add(-queueWait),
+		queueWait:   queueWait,
+		wait:        time.Since(start),
+		method:      req.Method,
+		url:         req.URL,
+		proto:       req.Proto,
+		reqHeader:   req.Header.Clone(),
+		reqMimeType: req.Header.Get("Content-Type"),
+		reqBodySize: req.ContentLength,
+		err:         err,
+	}
+
+	// the body of the request was already consumed, but might be available via GetBody
+	if req.GetBody != nil && r.args.MaxBodySize > 0 {
+		if body, err := req.GetBody(); err == nil {
+			record.reqBody, _ = ioutil.ReadAll(io.LimitReader(body, int64(r.args.MaxBodySize)))
+			body.Close()
+		}
+	}
+
+	if res != nil {
+		record.status = res.StatusCode
+		record.resProto = res.Proto
+		record.resHeader = res.Header.Clone()
+	}
+
+	r.mutex.Lock()
+	r.records = append(r.records, record)
+	r.mutex.Unlock()
+
+	if res == nil {
+		return nil
+	}
+
+	captured := *res
+	captured.Body = &harBody{ReadCloser: res.Body, recorder: r, record: record, start: time.Now()}
+	return &captured
+}
+
+// harBody captures a response body for a harRecord, while it is being read.
+type harBody struct {
+	io.ReadCloser
+
+	recorder *HARRecorder
+	record   *harRecord
+	start    time.Time
+	doneOnce sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.recorder.mutex.Lock()
+	b.record.resSize += int64(n)
+	if remaining := b.recorder.args.MaxBodySize - b.record.resBody.Len(); remaining > 0 {
+		if n > remaining {
+			b.record.resBody.Write(p[:remaining])
+			b.record.truncated = true
+		} else {
+			b.record.resBody.Write(p[:n])
+		}
+	} else if n > 0 {
+		b.record.truncated = true
+	}
+
+	if err != nil && err != io.EOF && b.record.err == nil {
+		b.record.err = err
+	}
+	b.recorder.mutex.Unlock()
+
+	if err != nil {
+		b.done()
+	}
+
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+
+	return err
+}
+
+func (b *harBody) done() {
+	b.doneOnce.Do(func() {
+		b.recorder.mutex.Lock()
+		b.record.receive = time.Since(b.start)
+		b.recorder.mutex.Unlock()
+	})
+}
+
+// entry converts the record into a HAR entry. The mutex must be held.
+func (r *HARRecorder) entry(record *harRecord) harEntry {
+	timings := harTimings{
+		Blocked: milliseconds(record.queueWait),
+		DNS:     -1,
+		Connect: -1,
+		SSL:     -1,
+		Wait:    milliseconds(record.wait),
+		Receive: milliseconds(record.receive),
+	}
+
+	entry := harEntry{
+		StartedDateTime: record.started.Format(time.RFC3339Nano),
+		Time:            timings.Blocked + timings.Wait + timings.Receive,
+		Request: harRequest{
+			Method:      record.method,
+			URL:         r.redactURL(record.url),
+			HTTPVersion: record.proto,
+			Cookies:     []harCookie{},
+			Headers:     r.headers(record.reqHeader),
+			QueryString: r.queryString(record.url),
+			HeadersSize: -1,
+			BodySize:    record.reqBodySize,
+		},
+		Response: harResponse{
+			Status:      record.status,
+			StatusText:  http.StatusText(record.status),
+			HTTPVersion: record.resProto,
+			Cookies:     []harCookie{},
+			Headers:     r.headers(record.resHeader),
+			Content: harContent{
+				Size:     record.resSize,
+				MimeType: record.resHeader.Get("Content-Type"),
+			},
+			RedirectURL: r.redactLocation(record.resHeader.Get("Location")),
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Cache:   struct{}{},
+		Timings: timings,
+	}
+
+	if record.reqBody != nil {
+		text, encoding := harText(record.reqBody)
+		entry.Request.PostData = &harPostData{MimeType: record.reqMimeType, Text: text}
+		if encoding != "" {
+			entry.Request.PostData.Comment = encoding + " encoded"
+		}
+	}
+
+	if record.resBody.Len() > 0 {
+		entry.Response.Content.Text, entry.Response.Content.Encoding = harText(record.resBody.Bytes())
+	}
+
+	if record.truncated {
+		entry.Response.Content.Comment = "truncated to " + strconv.Itoa(record.resBody.Len()) + " bytes"
+	}
+
+	if record.err != nil {
+		entry.Error = r.redactError(record.url, record.err)
+	}
+
+	return entry
+}
+
+func (r *HARRecorder) headers(header http.Header) []harNameValue {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := []harNameValue{}
+	for _, name := range names {
+		for _, value := range header[name] {
+			switch canonical := http.CanonicalHeaderKey(name); {
+			case r.redactHeaders[canonical]:
+				value = harRedacted
+			case canonical == "Location" || canonical == "Content-Location":
+				value = r.redactLocation(value)
+			}
+			headers = append(headers, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func (r *HARRecorder) redactQuery(query url.Values) url.Values {
+	redacted := make(url.Values, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			if r.redactParams[name] {
+				value = harRedacted
+			}
+			redacted.Add(name, value)
+		}
+	}
+
+	return redacted
+}
+
+func (r *HARRecorder) redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	redacted := *u
+	redacted.User = nil
+	if u.RawQuery != "" && len(r.redactParams) > 0 {
+		redacted.RawQuery = r.redactQuery(u.Query()).Encode()
+	}
+
+	return redacted.String()
+}
+
+// redactLocation redacts the (possibly relative) url of a Location header.
+// Unparsable urls are dropped, as they cannot be redacted reliably.
+func (r *HARRecorder) redactLocation(location string) string {
+	if location == "" {
+		return ""
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+
+	return r.redactURL(u)
+}
+
+// redactError returns the error text, with the request url (and any url carried by a
+// wrapped *url.Error, e.g. of a redirect target) redacted.
+func (r *HARRecorder) redactError(u *url.URL, err error) string {
+	text := err.Error()
+
+	var urls []string
+	if u != nil {
+		urls = append(urls, u.String())
+	}
+
+	for unwrapped := err; unwrapped != nil; unwrapped = errors.Unwrap(unwrapped) {
+		if urlErr, ok := unwrapped.(*url.Error); ok {
+			urls = append(urls, urlErr.URL)
+		}
+	}
+
+	for _, raw := range urls {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		redacted := r.redactURL(parsed)
+		text = strings.Replace(text, strconv.Quote(raw), strconv.Quote(redacted), -1)
+		text = strings.Replace(text, raw, redacted, -1)
+	}
+
+	return text
+}
+
+func (r *HARRecorder) queryString(u *url.URL) []harNameValue {
+	queryString := []harNameValue{}
+	if u == nil {
+		return queryString
+	}
+
+	query := r.redactQuery(u.Query())
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range query[name] {
+			queryString = append(queryString, harNameValue{Name: name, Value: value})
+		}
+	}
+
+	return queryString
+}
+
+// harText returns the body as text - base64 encoded, if it is not valid utf-8.
+func harText(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+
+	// custom field (prefixed with an underscore, as mandated by the specification)
+	Error string `json:"_error,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harCookie    `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
@@ -0,0 +1,172 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testHAR struct {
+	Log struct {
+		Version string `json:"version"`
+		Entries []struct {
+			Request struct {
+				Method      string `json:"method"`
+				URL         string `json:"url"`
+				Headers     []struct{ Name, Value string }
+				QueryString []struct{ Name, Value string }
+				PostData    *struct{ Text string }
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []struct{ Name, Value string }
+				Content struct {
+					Size    int64  `json:"size"`
+					Text    string `json:"text"`
+					Comment string `json:"comment"`
+				} `json:"content"`
+			} `json:"response"`
+			Timings map[string]float64 `json:"timings"`
+			Error   string             `json:"_error"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Tests that requests are recorded as HAR, with bodies truncated and secrets redacted.
+func Test_HARRecorder(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write([]byte("response:" + string(body)))
+	}))
+	defer server.Close()
+
+	recorder := bulk.NewHARRecorder(
+		bulk.HARMaxBodySize(12),
+		bulk.HARRedactHeaders("X-Api-Key"),
+		bulk.HARRedactQueryParams("token"),
+	)
+	executor := bulk.NewExecutor(bulk.RecordHAR(recorder))
+	defer executor.Close()
+
+	payload := []byte("payload")
+
+	// when
+	results := executor.AddRequestsWithInterceptor(context.Background(), func(r *http.Request) error {
+		r.Method = http.MethodPost
+		r.Header.Set("Authorization", "Bearer secret-token")
+		r.Header.Set("X-Api-Key", "secret-key")
+		r.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(payload)), nil
+		}
+		return nil
+	}, server.URL+"/items?token=secret-query&page=1")
+
+	result := <-results[0]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+	body, _ := ioutil.ReadAll(result.Res().Body)
+	result.Res().Body.Close()
+
+	failed := <-executor.AddRequests(context.Background(), "http://127.0.0.1:1/unreachable")[0]
+
+	buffer := &bytes.Buffer{}
+	if _, err := recorder.WriteTo(buffer); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	if string(body) != "response:payload" {
+		t.Errorf("expected unaltered body, got %q", body)
+	}
+
+	if strings.Contains(buffer.String(), "secret") {
+		t.Errorf("expected secrets to be redacted, got %s", buffer.String())
+	}
+
+	var har testHAR
+	if err := json.Unmarshal(buffer.Bytes(), &har); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("unexpected har %+v", har)
+	}
+
+	entry := har.Log.Entries[0]
+	if entry.Request.Method != http.MethodPost || entry.Request.PostData == nil || entry.Request.PostData.Text != "payload" {
+		t.Errorf("unexpected request %+v", entry.Request)
+	}
+
+	if !strings.Contains(entry.Request.URL, "token=%5BREDACTED%5D") || len(entry.Request.QueryString) != 2 {
+		t.Errorf("expected redacted query, got %s %+v", entry.Request.URL, entry.Request.QueryString)
+	}
+
+	content := entry.Response.Content
+	if entry.Response.Status != http.StatusOK || content.Size != 16 || content.Text != "response:pay" || content.Comment == "" {
+		t.Errorf("unexpected response %+v", entry.Response)
+	}
+
+	if _, ok := entry.Timings["wait"]; !ok {
+		t.Errorf("expected timings, got %+v", entry.Timings)
+	}
+
+	if failed.Err() == nil || har.Log.Entries[1].Error != failed.Err().Error() || har.Log.Entries[1].Response.Status != 0 {
+		t.Errorf("expected recorded error, got %+v", har.Log.Entries[1])
+	}
+}
+
+// Tests that redacted query parameters do not leak via recorded errors or redirect locations.
+func Test_HARRecorder_RedactedErrorAndLocation(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/next?token=secret-location&page=2")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	recorder := bulk.NewHARRecorder(bulk.HARRedactQueryParams("token"))
+	executor := bulk.NewExecutor(
+		bulk.RecordHAR(recorder),
+		bulk.Client(&http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}),
+	)
+	defer executor.Close()
+
+	// when
+	failed := <-executor.AddRequests(context.Background(), "http://127.0.0.1:1/x?token=secret-error")[0]
+	redirected := <-executor.AddRequests(context.Background(), server.URL+"/items")[0]
+	if redirected.Err() == nil {
+		redirected.Res().Body.Close()
+	}
+
+	buffer := &bytes.Buffer{}
+	if _, err := recorder.WriteTo(buffer); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	if failed.Err() == nil || !strings.Contains(failed.Err().Error(), "secret-error") {
+		t.Fatalf("expected unredacted error for the caller, got %v", failed.Err())
+	}
+
+	if strings.Contains(buffer.String(), "secret") {
+		t.Errorf("expected secrets to be redacted, got %s", buffer.String())
+	}
+
+	if !strings.Contains(buffer.String(), "token=%5BREDACTED%5D") {
+		t.Errorf("expected redacted urls, got %s", buffer.String())
+	}
+}
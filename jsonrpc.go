@@ -0,0 +1,305 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+var (
+	ErrRPCParse          = errors.New("json-rpc parse error")
+	ErrRPCInvalidRequest = errors.New("json-rpc invalid request")
+	ErrRPCMethodNotFound = errors.New("json-rpc method not found")
+	ErrRPCInvalidParams  = errors.New("json-rpc invalid params")
+	ErrRPCInternal       = errors.New("json-rpc internal error")
+	ErrRPCServer         = errors.New("json-rpc server error")
+	ErrRPCNoResponse     = errors.New("json-rpc response missing")
+)
+
+// RPCError is an error object returned by a JSON-RPC 2.0 server. Via errors.Is,
+// it matches the sentinel error corresponding to its (predefined) code, such as
+// ErrRPCMethodNotFound for -32601.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is the sentinel error corresponding to the code.
+func (e *RPCError) Is(target error) bool {
+	switch e.Code {
+	case -32700:
+		return target == ErrRPCParse
+	case -32600:
+		return target == ErrRPCInvalidRequest
+	case -32601:
+		return target == ErrRPCMethodNotFound
+	case -32602:
+		return target == ErrRPCInvalidParams
+	case -32603:
+		return target == ErrRPCInternal
+	}
+
+	return e.Code >= -32099 && e.Code <= -32000 && target == ErrRPCServer
+}
+
+// RPCCall is a single JSON-RPC 2.0 call. After execution, the result is decoded
+// into Result (if not nil), and Err carries the error of the call, if any.
+type RPCCall struct {
+	Method string
+	Params interface{}
+	Result interface{}
+
+	Err error
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// RPCClientOptions is the option-wrapper for defining the workings of an RPCClient.
+type RPCClientOptions struct {
+	ModifyRequest func(r *http.Request) error
+}
+
+type RPCClientOption func(*RPCClientOptions)
+
+// RPCInterceptor sets a hook for modifying each request prior to sending.
+func RPCInterceptor(modifyRequest func(r *http.Request) error) RPCClientOption {
+	return func(args *RPCClientOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// RPCClient is a JSON-RPC 2.0 client for a single endpoint, which issues its
+// requests via an Executor.
+type RPCClient struct {
+	executor Requester
+	url      string
+	args     *RPCClientOptions
+
+	nextID int64
+}
+
+// NewRPCClient instantiates a new RPCClient for the given endpoint.
+func NewRPCClient(executor Requester, url string, setters ...RPCClientOption) *RPCClient {
+	args := &RPCClientOptions{}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	return &RPCClient{executor: executor, url: url, args: args}
+}
+
+// Call issues a single call, and decodes its result into result (if not nil).
+func (c *RPCClient) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	call := &RPCCall{Method: method, Params: params, Result: result}
+	c.FanOut(ctx, call)
+
+	return call.Err
+}
+
+// Batch issues all calls as a single batch (that is, a json array) within one
+// request. The error of each call is set on the call itself. If any call failed,
+// a BulkError is returned in addition.
+func (c *RPCClient) Batch(ctx context.Context, calls ...*RPCCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	requests := make([]rpcRequest, len(calls))
+	for i, call := range calls {
+		requests[i] = c.newRequest(call)
+	}
+
+	ctx, batch := startRequesterBatch(ctx, c.executor, "jsonrpc batch", len(calls))
+	responses, err := c.post(ctx, requests)
+	batch.end(err)
+	if err != nil {
+		for _, call := range calls {
+			call.Err = err
+		}
+
+		return c.collectErrors(calls)
+	}
+
+	// a single error object (e.g. for a parse error) applies to all calls
+	if len(responses) == 1 && responses[0].Error != nil && string(responses[0].ID) == "null" {
+		for _, call := range calls {
+			call.Err = responses[0].Error
+		}
+
+		return c.collectErrors(calls)
+	}
+
+	byID := make(map[string]rpcResponse, len(responses))
+	for _, response := range responses {
+		byID[normalizeRPCID(response.ID)] = response
+	}
+
+	for i, call := range calls {
+		response, ok := byID[strconv.FormatInt(requests[i].ID, 10)]
+		if !ok {
+			call.Err = ErrRPCNoResponse
+			continue
+		}
+
+		call.Err = decodeRPCResponse(response, call.Result)
+	}
+
+	return c.collectErrors(calls)
+}
+
+// FanOut issues each call as an individual request, in parallel. The error of each
+// call is set on the call itself. If any call failed, a BulkError is returned in addition.
+func (c *RPCClient) FanOut(ctx context.Context, calls ...*RPCCall) error {
+	done := make(chan struct{}, len(calls))
+	for _, call := range calls {
+		go func(call *RPCCall) {
+			defer func() { done <- struct{}{} }()
+
+			request := c.newRequest(call)
+			responses, err := c.post(ctx, request)
+			if err != nil {
+				call.Err = err
+				return
+			}
+
+			if len(responses) != 1 {
+				call.Err = ErrRPCNoResponse
+				return
+			}
+
+			call.Err = decodeRPCResponse(responses[0], call.Result)
+		}(call)
+	}
+
+	for range calls {
+		<-done
+	}
+
+	return c.collectErrors(calls)
+}
+
+func (c *RPCClient) newRequest(call *RPCCall) rpcRequest {
+	return rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddInt64(&c.nextID, 1),
+		Method:  call.Method,
+		Params:  call.Params,
+	}
+}
+
+// post sends the payload, and decodes the response - which may either
+// be a single response object, or an array of them.
+func (c *RPCClient) post(ctx context.Context, payload interface{}) ([]rpcResponse, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	result := <-addRequest(ctx, c.executor, postInterceptor("application/json", body, c.args.ModifyRequest), "", c.url)
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	defer result.Res().Body.Close()
+	responseBody, err := ioutil.ReadAll(result.Res().Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON-RPC over HTTP may use error status codes, while still responding with a valid body
+	responseBody = bytes.TrimSpace(responseBody)
+	if len(responseBody) == 0 {
+		if result.Res().StatusCode < 200 || result.Res().StatusCode > 299 {
+			return nil, fmt.Errorf("unexpected status %d: %w", result.Res().StatusCode, ErrRequestFailed)
+		}
+
+		return nil, ErrRPCNoResponse
+	}
+
+	if responseBody[0] == '[' {
+		var responses []rpcResponse
+		if err := json.Unmarshal(responseBody, &responses); err != nil {
+			return nil, err
+		}
+
+		return responses, nil
+	}
+
+	var response rpcResponse
+	if err := json.Unmarshal(responseBody, &response); err != nil {
+		if result.Res().StatusCode < 200 || result.Res().StatusCode > 299 {
+			return nil, fmt.Errorf("unexpected status %d: %w", result.Res().StatusCode, ErrRequestFailed)
+		}
+
+		return nil, err
+	}
+
+	return []rpcResponse{response}, nil
+}
+
+func (c *RPCClient) collectErrors(calls []*RPCCall) error {
+	var bulkErr BulkError
+	for i, call := range calls {
+		if call.Err != nil {
+			bulkErr = append(bulkErr, &URLError{Index: i, URL: c.url, Err: call.Err})
+		}
+	}
+
+	if len(bulkErr) > 0 {
+		return bulkErr
+	}
+
+	return nil
+}
+
+func decodeRPCResponse(response rpcResponse, target interface{}) error {
+	if response.Error != nil {
+		return response.Error
+	}
+
+	if target == nil {
+		return nil
+	}
+
+	return json.Unmarshal(response.Result, target)
+}
+
+// normalizeRPCID turns numeric and string ids into the same representation.
+func normalizeRPCID(id json.RawMessage) string {
+	var value interface{}
+	if err := json.Unmarshal(id, &value); err != nil {
+		return string(id)
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return string(id)
+	}
+}
@@ -0,0 +1,150 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type testRPCRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []int           `json:"params"`
+}
+
+func handleTestRPC(request testRPCRequest) map[string]interface{} {
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+	if request.Method != "add" {
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+		return response
+	}
+
+	sum := 0
+	for _, param := range request.Params {
+		sum += param
+	}
+	response["result"] = sum
+
+	return response
+}
+
+func newRPCServer(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if len(body) > 0 && body[0] == '[' {
+			var batch []testRPCRequest
+			json.Unmarshal(body, &batch)
+
+			// answer in reverse order, to exercise id correlation
+			responses := make([]interface{}, len(batch))
+			for i, request := range batch {
+				responses[len(batch)-1-i] = handleTestRPC(request)
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+
+		var request testRPCRequest
+		json.Unmarshal(body, &request)
+		json.NewEncoder(w).Encode(handleTestRPC(request))
+	}))
+}
+
+// Tests that batches are sent within one request, and results are correlated by id.
+func Test_RPCClient_Batch(t *testing.T) {
+	// given
+	var requests int32
+	server := newRPCServer(&requests)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	client := bulk.NewRPCClient(executor, server.URL)
+
+	var first, second int
+	calls := []*bulk.RPCCall{
+		{Method: "add", Params: []int{1, 2}, Result: &first},
+		{Method: "add", Params: []int{3, 4}, Result: &second},
+		{Method: "unknown"},
+	}
+
+	// when
+	err := client.Batch(context.Background(), calls...)
+
+	// then
+	if first != 3 || second != 7 {
+		t.Errorf("unexpected results %d and %d", first, second)
+	}
+
+	if !errors.Is(calls[2].Err, bulk.ErrRPCMethodNotFound) {
+		t.Errorf("expected method not found error, got %v", calls[2].Err)
+	}
+
+	var bulkErr bulk.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr) != 1 || bulkErr[0].Index != 2 {
+		t.Errorf("expected bulk error for third call, got %v", err)
+	}
+
+	var rpcErr *bulk.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("expected rpc error, got %v", err)
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 1 {
+		t.Errorf("expected 1 request, got %d", requests)
+	}
+}
+
+// Tests that calls are fanned out as individual requests.
+func Test_RPCClient_FanOut(t *testing.T) {
+	// given
+	var requests int32
+	server := newRPCServer(&requests)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	client := bulk.NewRPCClient(executor, server.URL)
+
+	var first, second int
+	calls := []*bulk.RPCCall{
+		{Method: "add", Params: []int{1, 2}, Result: &first},
+		{Method: "add", Params: []int{3, 4}, Result: &second},
+	}
+
+	// when
+	err := client.FanOut(context.Background(), calls...)
+
+	// then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if first != 3 || second != 7 {
+		t.Errorf("unexpected results %d and %d", first, second)
+	}
+
+	if requests := atomic.LoadInt32(&requests); requests != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+
+	// and when
+	var sum int
+	err = client.Call(context.Background(), "add", []int{5, 6}, &sum)
+
+	// then
+	if err != nil || sum != 11 {
+		t.Errorf("expected 11, got %d (%v)", sum, err)
+	}
+}
@@ -4,46 +4,159 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"time"
 )
 
 var (
-	ErrRequestFailed = errors.New("http request failed")
+	ErrRequestFailed       = errors.New("http request failed")
+	ErrMissingLastModified = errors.New("missing or invalid last-modified header")
 )
 
+// LastModOptions is the option-wrapper for defining the workings of FetchLastModified.
+type LastModOptions struct {
+	Method        string
+	Lenient       bool
+	ModifyRequest func(r *http.Request) error
+}
+
+type LastModOption func(*LastModOptions)
+
+// LastModMethod sets the http method used for fetching the last modification date.
+// Per default, HEAD is used.
+func LastModMethod(method string) LastModOption {
+	return func(args *LastModOptions) {
+		args.Method = method
+	}
+}
+
+// LastModLenient enables (or disables) the lenient mode. In lenient mode, a missing
+// or unparseable last-modified header yields the zero time instead of an error.
+func LastModLenient(lenient bool) LastModOption {
+	return func(args *LastModOptions) {
+		args.Lenient = lenient
+	}
+}
+
+// LastModInterceptor sets a hook for modifying each request prior to sending. The
+// hook is executed after the method has been set, so it may still override it.
+func LastModInterceptor(modifyRequest func(r *http.Request) error) LastModOption {
+	return func(args *LastModOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// LastModResult is the last modification date of a single url, or the error
+// that occurred while fetching it.
+type LastModResult struct {
+	URL string
+	// StatusCode is the status code of the response, or 0 if no response was received.
+	StatusCode int
+	Time       time.Time
+	Err        error
+}
+
+// FetchLastModified fetches the last modification date for multiple urls at once.
+// The returned slice contains a LastModResult for each url, in the same order.
+//
+// The date is parsed from the last-modified header, in any format accepted by
+// http.ParseTime. A 404 response yields the unix epoch, and other non 2xx (or 304)
+// status codes an error wrapping ErrRequestFailed.
+func FetchLastModified(ctx context.Context, executor Requester, urls []string, setters ...LastModOption) []LastModResult {
+	args := &LastModOptions{
+		Method: http.MethodHead,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	modifyRequest := func(r *http.Request) error {
+		r.Method = args.Method
+		if args.ModifyRequest != nil {
+			return args.ModifyRequest(r)
+		}
+
+		return nil
+	}
+
+	results := executor.AddRequestsWithInterceptor(ctx, modifyRequest, urls...)
+
+	lastMods := make([]LastModResult, len(urls))
+	for i, resultChan := range results {
+		result := <-resultChan
+		lastModified, err := handleLastModResponse(result, args.Lenient)
+
+		lastMods[i] = LastModResult{URL: urls[i], Time: lastModified, Err: err}
+		if result.res != nil {
+			lastMods[i].StatusCode = result.res.StatusCode
+		}
+	}
+
+	return lastMods
+}
+
 // FetchLastModDatesForURLs fetches the last modification date for multiple urls at once.
+// If fetching fails for any url, a BulkError is returned.
 func FetchLastModDatesForURLs(
-	ctx context.Context, executor *Executor, modifyRequest func(r *http.Request) error, urls ...string,
+	ctx context.Context, executor Requester, modifyRequest func(r *http.Request) error, urls ...string,
 ) ([]time.Time, error) {
-	if len(urls) > 0 {
-		initialContext, cancel := context.WithCancel(ctx)
-		defer cancel()
+	lastMods := FetchLastModified(ctx, executor, urls, LastModInterceptor(modifyRequest))
+
+	var bulkErr BulkError
+	times := make([]time.Time, len(lastMods))
+	for i, lastMod := range lastMods {
+		if lastMod.Err != nil {
+			bulkErr = append(bulkErr, &URLError{Index: i, URL: lastMod.URL, StatusCode: lastMod.StatusCode, Err: lastMod.Err})
+			continue
+		}
+
+		times[i] = lastMod.Time
+	}
 
-		results := executor.AddRequestsWithInterceptor(initialContext, modifyRequest, urls...)
+	if len(bulkErr) > 0 {
+		return nil, bulkErr
+	}
+
+	return times, nil
+}
 
-		times := make([]time.Time, len(urls))
-		for i, result := range results {
-			lastModified, err := handleResponse(<-result)
-			if err != nil {
-				return nil, err
-			}
+// MaxLastModified returns the latest of all given last modification dates, e.g. for
+// building a cache key for a set of resources. If any of the results carries an
+// error, a BulkError is returned instead.
+func MaxLastModified(lastMods ...LastModResult) (time.Time, error) {
+	var (
+		max     time.Time
+		bulkErr BulkError
+	)
 
-			times[i] = lastModified
+	for i, lastMod := range lastMods {
+		if lastMod.Err != nil {
+			bulkErr = append(bulkErr, &URLError{Index: i, URL: lastMod.URL, StatusCode: lastMod.StatusCode, Err: lastMod.Err})
+			continue
+		}
+
+		if lastMod.Time.After(max) {
+			max = lastMod.Time
 		}
 	}
 
-	return []time.Time{}, nil
+	if len(bulkErr) > 0 {
+		return time.Time{}, bulkErr
+	}
+
+	return max, nil
 }
 
-func handleResponse(r Result) (time.Time, error) {
+func handleLastModResponse(r Result, lenient bool) (time.Time, error) {
 	if r.Err() != nil {
 		return time.Time{}, r.Err()
 	}
 
 	defer r.Res().Body.Close()
-	if _, err := ioutil.ReadAll(r.Res().Body); err != nil {
+	if _, err := io.Copy(ioutil.Discard, r.Res().Body); err != nil {
 		return time.Time{}, err
 	}
 
@@ -51,9 +164,18 @@ func handleResponse(r Result) (time.Time, error) {
 		return time.Unix(0, 0), nil
 	}
 
-	if r.Res().StatusCode != http.StatusOK && r.Res().StatusCode != http.StatusNotModified {
+	if (r.Res().StatusCode < 200 || r.Res().StatusCode > 299) && r.Res().StatusCode != http.StatusNotModified {
 		return time.Time{}, fmt.Errorf("failed to get last-modified date for %s: %w", r.URL(), ErrRequestFailed)
 	}
 
-	return time.Parse(time.RFC1123, r.Res().Header.Get("last-modified"))
+	lastModified, err := http.ParseTime(r.Res().Header.Get("last-modified"))
+	if err != nil {
+		if lenient {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, fmt.Errorf("failed to parse last-modified date for %s: %w", r.URL(), ErrMissingLastModified)
+	}
+
+	return lastModified, nil
 }
@@ -0,0 +1,160 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newLastModServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rfc1123", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("expected HEAD request, got %s", r.Method)
+		}
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	})
+	mux.HandleFunc("/rfc850", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "Tuesday, 03-Jan-06 15:04:05 GMT")
+	})
+	mux.HandleFunc("/invalid", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", "yesterday")
+	})
+	mux.HandleFunc("/failing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// Tests that FetchLastModified returns a result per url, in order.
+func Test_FetchLastModified(t *testing.T) {
+	// given
+	server := newLastModServer(t)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	results := bulk.FetchLastModified(context.Background(), executor, []string{
+		server.URL + "/rfc1123",
+		server.URL + "/rfc850",
+		server.URL + "/missing",
+		server.URL + "/invalid",
+		server.URL + "/failing",
+	})
+
+	// then
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+
+	if !results[0].Time.Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)) || results[0].Err != nil {
+		t.Errorf("unexpected result for RFC1123 date: %+v", results[0])
+	}
+
+	if !results[1].Time.Equal(time.Date(2006, 1, 3, 15, 4, 5, 0, time.UTC)) || results[1].Err != nil {
+		t.Errorf("unexpected result for RFC850 date: %+v", results[1])
+	}
+
+	if !results[2].Time.Equal(time.Unix(0, 0)) || results[2].Err != nil {
+		t.Errorf("unexpected result for missing resource: %+v", results[2])
+	}
+
+	if !errors.Is(results[3].Err, bulk.ErrMissingLastModified) {
+		t.Errorf("unexpected result for invalid date: %+v", results[3])
+	}
+
+	if !errors.Is(results[4].Err, bulk.ErrRequestFailed) || results[4].StatusCode != http.StatusInternalServerError {
+		t.Errorf("unexpected result for failing resource: %+v", results[4])
+	}
+}
+
+// Tests that in lenient mode, invalid dates yield the zero time.
+func Test_FetchLastModified_Lenient(t *testing.T) {
+	// given
+	server := newLastModServer(t)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	results := bulk.FetchLastModified(context.Background(), executor, []string{
+		server.URL + "/invalid",
+	}, bulk.LastModLenient(true))
+
+	// then
+	if !results[0].Time.IsZero() || results[0].Err != nil {
+		t.Errorf("unexpected result for invalid date: %+v", results[0])
+	}
+}
+
+// Tests that FetchLastModDatesForURLs returns the fetched dates.
+func Test_FetchLastModDatesForURLs(t *testing.T) {
+	// given
+	server := newLastModServer(t)
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	// when
+	times, err := bulk.FetchLastModDatesForURLs(context.Background(), executor, nil,
+		server.URL+"/rfc1123",
+		server.URL+"/rfc850",
+	)
+
+	// then
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if len(times) != 2 || !times[0].Equal(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected times %v", times)
+	}
+
+	// and when
+	_, err = bulk.FetchLastModDatesForURLs(context.Background(), executor, nil,
+		server.URL+"/rfc1123",
+		server.URL+"/failing",
+	)
+
+	// then
+	var bulkErr bulk.BulkError
+	if !errors.As(err, &bulkErr) || len(bulkErr) != 1 || bulkErr[0].Index != 1 {
+		t.Errorf("expected bulk error for second url, got %v", err)
+	}
+}
+
+// Tests that MaxLastModified returns the latest date.
+func Test_MaxLastModified(t *testing.T) {
+	// given
+	latest := time.Date(2021, 6, 25, 0, 0, 0, 0, time.UTC)
+
+	// when
+	max, err := bulk.MaxLastModified(
+		bulk.LastModResult{Time: latest.Add(-time.Hour)},
+		bulk.LastModResult{Time: latest},
+		bulk.LastModResult{Time: time.Unix(0, 0)},
+	)
+
+	// then
+	if err != nil || !max.Equal(latest) {
+		t.Errorf("expected %s, got %s (%v)", latest, max, err)
+	}
+
+	// and when
+	_, err = bulk.MaxLastModified(bulk.LastModResult{Err: bulk.ErrRequestFailed})
+
+	// then
+	if !errors.Is(err, bulk.ErrRequestFailed) {
+		t.Errorf("expected request failed error, got %v", err)
+	}
+}
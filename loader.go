@@ -0,0 +1,227 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeyNotLoaded = errors.New("key missing from batch response")
+)
+
+// LoaderOptions is the option-wrapper for defining the workings of a Loader.
+type LoaderOptions struct {
+	Wait          time.Duration
+	MaxBatchSize  int
+	ModifyRequest func(r *http.Request) error
+}
+
+type LoaderOption func(*LoaderOptions)
+
+// LoaderWait sets the duration, which keys are collected for (starting with the
+// first one) before the batch request is issued. Per default, 2ms are used.
+func LoaderWait(wait time.Duration) LoaderOption {
+	return func(args *LoaderOptions) {
+		args.Wait = wait
+	}
+}
+
+// LoaderMaxBatchSize sets the maximum amount of keys per batch request. If reached,
+// the batch request is issued immediately. Per default, 100 keys are used. You can
+// use -1 to indicate no limit.
+func LoaderMaxBatchSize(size int) LoaderOption {
+	return func(args *LoaderOptions) {
+		args.MaxBatchSize = size
+	}
+}
+
+// LoaderInterceptor sets a hook for modifying each batch request prior to sending.
+func LoaderInterceptor(modifyRequest func(r *http.Request) error) LoaderOption {
+	return func(args *LoaderOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// Loader collects individual keys, and loads them via a single batch request
+// (such as /items?ids=1,2,3). The batch response is then split back into
+// individual futures per key.
+type Loader struct {
+	ctx      context.Context
+	executor Requester
+	batchURL func(keys []string) (string, error)
+	split    func(keys []string, body []byte) (map[string][]byte, error)
+	args     *LoaderOptions
+
+	mutex sync.Mutex
+	batch *loaderBatch
+}
+
+type loaderBatch struct {
+	keys    []string
+	futures map[string]*Future
+	timer   *time.Timer
+}
+
+// NewLoader instantiates a new Loader. All batch requests are issued with the given
+// context, so a Loader is typically bound to a single incoming request.
+//
+// For each batch, batchURL builds the url to request for the given keys. Afterwards,
+// split splits the (successful) response body into the individual bodies per key.
+// Keys missing from the returned map are reported as ErrKeyNotLoaded.
+func NewLoader(
+	ctx context.Context,
+	executor Requester,
+	batchURL func(keys []string) (string, error),
+	split func(keys []string, body []byte) (map[string][]byte, error),
+	setters ...LoaderOption,
+) *Loader {
+	// Default Options
+	args := &LoaderOptions{
+		Wait:         2 * time.Millisecond,
+		MaxBatchSize: 100,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	return &Loader{
+		ctx:      ctx,
+		executor: executor,
+		batchURL: batchURL,
+		split:    split,
+		args:     args,
+	}
+}
+
+// Load schedules the given key to be loaded with the next batch, and returns a
+// Future for its individual result. Loading the same key multiple times within
+// a batch returns the same Future.
+func (l *Loader) Load(key string) *Future {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.batch == nil {
+		batch := &loaderBatch{futures: map[string]*Future{}}
+		batch.timer = time.AfterFunc(l.args.Wait, func() {
+			l.dispatch(batch)
+		})
+		l.batch = batch
+	}
+
+	batch := l.batch
+	if future, ok := batch.futures[key]; ok {
+		return future
+	}
+
+	future := &Future{resultChan: make(chan Result, 1)}
+	batch.futures[key] = future
+	batch.keys = append(batch.keys, key)
+
+	if l.args.MaxBatchSize > 0 && len(batch.keys) >= l.args.MaxBatchSize {
+		batch.timer.Stop()
+		l.batch = nil
+		go l.execute(batch)
+	}
+
+	return future
+}
+
+// LoadMany schedules all given keys to be loaded, as described in Load.
+func (l *Loader) LoadMany(keys ...string) []*Future {
+	futures := make([]*Future, len(keys))
+	for i, key := range keys {
+		futures[i] = l.Load(key)
+	}
+
+	return futures
+}
+
+// Flush issues the pending batch immediately, without waiting any further.
+func (l *Loader) Flush() {
+	l.mutex.Lock()
+	batch := l.batch
+	l.mutex.Unlock()
+
+	if batch != nil {
+		batch.timer.Stop()
+		l.dispatch(batch)
+	}
+}
+
+// dispatch executes the batch, unless this already happened.
+func (l *Loader) dispatch(batch *loaderBatch) {
+	l.mutex.Lock()
+	if l.batch != batch {
+		l.mutex.Unlock()
+		return
+	}
+	l.batch = nil
+	l.mutex.Unlock()
+
+	l.execute(batch)
+}
+
+func (l *Loader) execute(batch *loaderBatch) {
+	url, err := l.batchURL(batch.keys)
+	if err != nil {
+		batch.fail(url, err)
+		return
+	}
+
+	ctx, span := startRequesterBatch(l.ctx, l.executor, "loader batch", len(batch.keys))
+	result := <-addRequest(ctx, l.executor, l.args.ModifyRequest, "", url)
+	span.end(result.Err())
+	if result.Err() != nil {
+		batch.fail(url, result.Err())
+		return
+	}
+
+	res := result.res
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		batch.fail(url, err)
+		return
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		batch.fail(url, fmt.Errorf("unexpected status %d for batch %s: %w", res.StatusCode, url, ErrRequestFailed))
+		return
+	}
+
+	parts, err := l.split(batch.keys, body)
+	if err != nil {
+		batch.fail(url, err)
+		return
+	}
+
+	for _, key := range batch.keys {
+		part, ok := parts[key]
+		if !ok {
+			batch.futures[key].resultChan <- Result{key: key, url: url, dur: result.dur, err: fmt.Errorf("%s: %w", key, ErrKeyNotLoaded)}
+			continue
+		}
+
+		partRes := *res
+		partRes.Header = res.Header.Clone()
+		partRes.Header.Set("Content-Length", strconv.Itoa(len(part)))
+		partRes.ContentLength = int64(len(part))
+		partRes.Body = ioutil.NopCloser(bytes.NewReader(part))
+
+		batch.futures[key].resultChan <- Result{key: key, url: url, res: &partRes, dur: result.dur}
+	}
+}
+
+func (batch *loaderBatch) fail(url string, err error) {
+	for _, key := range batch.keys {
+		batch.futures[key].resultChan <- Result{key: key, url: url, err: err}
+	}
+}
@@ -0,0 +1,139 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type loaderItem struct {
+	ID string `json:"id"`
+}
+
+func newLoaderServer() (*httptest.Server, func() []string) {
+	var (
+		mutex   sync.Mutex
+		batches []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := r.URL.Query().Get("ids")
+
+		mutex.Lock()
+		batches = append(batches, ids)
+		mutex.Unlock()
+
+		var items []loaderItem
+		for _, id := range strings.Split(ids, ",") {
+			if id != "missing" {
+				items = append(items, loaderItem{ID: id})
+			}
+		}
+		json.NewEncoder(w).Encode(items)
+	}))
+
+	return server, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return append([]string(nil), batches...)
+	}
+}
+
+func newTestLoader(executor *bulk.Executor, serverURL string, setters ...bulk.LoaderOption) *bulk.Loader {
+	return bulk.NewLoader(context.Background(), executor,
+		func(keys []string) (string, error) {
+			return serverURL + "/items?ids=" + strings.Join(keys, ","), nil
+		},
+		func(keys []string, body []byte) (map[string][]byte, error) {
+			var items []json.RawMessage
+			if err := json.Unmarshal(body, &items); err != nil {
+				return nil, err
+			}
+
+			parts := map[string][]byte{}
+			for _, raw := range items {
+				var item loaderItem
+				if err := json.Unmarshal(raw, &item); err != nil {
+					return nil, err
+				}
+				parts[item.ID] = raw
+			}
+
+			return parts, nil
+		},
+		setters...,
+	)
+}
+
+// Tests that keys loaded within the wait window are loaded via a single batch request.
+func Test_Loader(t *testing.T) {
+	// given
+	server, batches := newLoaderServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	loader := newTestLoader(executor, server.URL, bulk.LoaderWait(time.Hour))
+
+	// when
+	futures := loader.LoadMany("1", "2", "missing")
+	duplicate := loader.Load("1")
+	loader.Flush()
+
+	// then
+	if duplicate != futures[0] {
+		t.Error("same key within a batch did not return the same future")
+	}
+
+	for i, id := range []string{"1", "2"} {
+		var item loaderItem
+		if err := futures[i].UnmarshalResponse(&item); err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+
+		if item.ID != id || futures[i].Get().Key() != id {
+			t.Errorf("expected item %s, got %+v", id, item)
+		}
+	}
+
+	if err := futures[2].Get().Err(); !errors.Is(err, bulk.ErrKeyNotLoaded) {
+		t.Errorf("expected key not loaded error, got %v", err)
+	}
+
+	if got := batches(); len(got) != 1 || got[0] != "1,2,missing" {
+		t.Errorf("unexpected batches %v", got)
+	}
+}
+
+// Tests that batches are issued once the maximum batch size is reached.
+func Test_Loader_MaxBatchSize(t *testing.T) {
+	// given
+	server, batches := newLoaderServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	loader := newTestLoader(executor, server.URL, bulk.LoaderWait(time.Millisecond), bulk.LoaderMaxBatchSize(2))
+
+	// when
+	if err := bulk.WaitAll(loader.LoadMany("1", "2", "3")...); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	// then
+	got := batches()
+	if len(got) != 2 || (got[0] != "1,2" && got[1] != "1,2") || (got[0] != "3" && got[1] != "3") {
+		t.Errorf("unexpected batches %v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package bulk
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the severity of a LogRecord. The values match the ones of log/slog.
+type LogLevel int
+
+const (
+	LogLevelDebug LogLevel = -4
+	LogLevelInfo  LogLevel = 0
+	LogLevelWarn  LogLevel = 4
+	LogLevelError LogLevel = 8
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "DEBUG"
+	case LogLevelInfo:
+		return "INFO"
+	case LogLevelWarn:
+		return "WARN"
+	case LogLevelError:
+		return "ERROR"
+	default:
+		return "LEVEL(" + strconv.Itoa(int(l)) + ")"
+	}
+}
+
+// LogRecord is a structured record of a single upstream request.
+type LogRecord struct {
+	Level   LogLevel
+	Message string
+
+	URL        string
+	Method     string
+	StatusCode int
+	Duration   time.Duration
+	QueueWait  time.Duration
+
+	// Attempts is the number of attempts made for the request. The Executor itself
+	// does not retry, so this is always 1 for requests issued by it.
+	Attempts int
+	Slow     bool
+	Err      error
+}
+
+// LogHandler receives the records emitted for requests issued by an Executor.
+// The context is the one of the request. Implementations must be safe for
+// concurrent use.
+type LogHandler interface {
+	Handle(ctx context.Context, record LogRecord)
+}
+
+// LogHandlerFunc is an adapter to allow the use of ordinary functions as LogHandler.
+type LogHandlerFunc func(ctx context.Context, record LogRecord)
+
+// Handle calls f(ctx, record).
+func (f LogHandlerFunc) Handle(ctx context.Context, record LogRecord) {
+	f(ctx, record)
+}
+
+// LoggerOptions is the option-wrapper for defining the workings of the Logger option.
+type LoggerOptions struct {
+	SuccessLevel LogLevel
+	SlowLevel    LogLevel
+	FailureLevel LogLevel
+
+	SlowThreshold time.Duration
+	SampleRate    float64
+}
+
+type LoggerOption func(*LoggerOptions)
+
+// LoggerLevels sets the levels for successful, slow and failed requests. A request
+// is considered failed, if it resulted in an error or a 5xx status code. Per default,
+// successful requests are logged with LogLevelDebug, slow ones with LogLevelWarn,
+// and failed ones with LogLevelError.
+func LoggerLevels(success, slow, failure LogLevel) LoggerOption {
+	return func(args *LoggerOptions) {
+		args.SuccessLevel = success
+		args.SlowLevel = slow
+		args.FailureLevel = failure
+	}
+}
+
+// LoggerSlowThreshold sets the duration, above which successful requests are
+// considered slow. Per default (or with 0), no request is considered slow.
+func LoggerSlowThreshold(threshold time.Duration) LoggerOption {
+	return func(args *LoggerOptions) {
+		args.SlowThreshold = threshold
+	}
+}
+
+// LoggerSampleRate sets the fraction (between 0 and 1) of successful requests, which
+// are logged. Slow and failed requests are always logged. Per default, all requests
+// are logged.
+func LoggerSampleRate(rate float64) LoggerOption {
+	return func(args *LoggerOptions) {
+		args.SampleRate = rate
+	}
+}
+
+// requestLogger emits log records for requests, as configured via LoggerOptions.
+type requestLogger struct {
+	handler LogHandler
+	args    LoggerOptions
+
+	// count of successful requests, for sampling
+	count *uint64
+}
+
+func newRequestLogger(handler LogHandler, args LoggerOptions) *requestLogger {
+	return &requestLogger{handler: handler, args: args, count: new(uint64)}
+}
+
+func (l *requestLogger) log(req *http.Request, result Result, queueWait time.Duration) {
+	record := LogRecord{
+		Level:     l.args.SuccessLevel,
+		Message:   "request finished",
+		URL:       req.URL.String(),
+		Method:    req.Method,
+		Duration:  result.dur,
+		QueueWait: queueWait,
+		Attempts:  1,
+		Err:       result.err,
+	}
+
+	if result.res != nil {
+		record.StatusCode = result.res.StatusCode
+	}
+
+	switch {
+	case result.failed():
+		record.Level = l.args.FailureLevel
+		record.Message = "request failed"
+	case l.args.SlowThreshold > 0 && result.dur > l.args.SlowThreshold:
+		record.Level = l.args.SlowLevel
+		record.Message = "slow request"
+		record.Slow = true
+	default:
+		if !l.sample() {
+			return
+		}
+	}
+
+	l.handler.Handle(req.Context(), record)
+}
+
+// sample deterministically decides whether to log the next successful request,
+// so that (over time) exactly the configured fraction of requests is logged.
+func (l *requestLogger) sample() bool {
+	if l.args.SampleRate >= 1 {
+		return true
+	}
+
+	if l.args.SampleRate <= 0 {
+		return false
+	}
+
+	n := atomic.AddUint64(l.count, 1)
+	return uint64(float64(n)*l.args.SampleRate) != uint64(float64(n-1)*l.args.SampleRate)
+}
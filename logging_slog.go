@@ -0,0 +1,49 @@
+//go:build go1.21
+// +build go1.21
+
+package bulk
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogHandler adapts a log/slog logger into a LogHandler. The fields of each
+// LogRecord are emitted as attributes, and its level is mapped to the equivalent
+// slog level.
+func SlogHandler(logger *slog.Logger) LogHandler {
+	return slogHandler{logger: logger}
+}
+
+type slogHandler struct {
+	logger *slog.Logger
+}
+
+func (h slogHandler) Handle(ctx context.Context, record LogRecord) {
+	level := slog.Level(record.Level)
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("url", record.URL),
+		slog.String("method", record.Method),
+		slog.Duration("duration", record.Duration),
+		slog.Duration("queue_wait", record.QueueWait),
+		slog.Int("attempts", record.Attempts),
+	}
+
+	if record.StatusCode != 0 {
+		attrs = append(attrs, slog.Int("status", record.StatusCode))
+	}
+
+	if record.Slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+
+	if record.Err != nil {
+		attrs = append(attrs, slog.String("error", record.Err.Error()))
+	}
+
+	h.logger.LogAttrs(ctx, level, record.Message, attrs...)
+}
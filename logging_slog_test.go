@@ -0,0 +1,45 @@
+//go:build go1.21
+// +build go1.21
+
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// Tests that the slog adapter emits records as structured attributes.
+func Test_SlogHandler(t *testing.T) {
+	// given
+	buffer := &bytes.Buffer{}
+	handler := bulk.SlogHandler(slog.New(slog.NewJSONHandler(buffer, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	// when
+	handler.Handle(context.Background(), bulk.LogRecord{Level: bulk.LogLevelDebug, Message: "request finished"})
+	handler.Handle(context.Background(), bulk.LogRecord{
+		Level:    bulk.LogLevelError,
+		Message:  "request failed",
+		URL:      "https://example.com",
+		Method:   "GET",
+		Duration: time.Second,
+		Attempts: 1,
+		Err:      errors.New("boom"),
+	})
+
+	// then
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buffer.Bytes(), &entry); err != nil {
+		t.Fatalf("expected exactly one json entry, got %q", buffer.String())
+	}
+
+	if entry["level"] != "ERROR" || entry["msg"] != "request failed" || entry["url"] != "https://example.com" || entry["error"] != "boom" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+}
@@ -0,0 +1,129 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogHandler struct {
+	mutex   sync.Mutex
+	records []bulk.LogRecord
+}
+
+func (h *recordingLogHandler) Handle(ctx context.Context, record bulk.LogRecord) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.records = append(h.records, record)
+}
+
+func (h *recordingLogHandler) byPath(server *httptest.Server, path string) []bulk.LogRecord {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var records []bulk.LogRecord
+	for _, record := range h.records {
+		if record.URL == server.URL+path {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+func newLoggingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			time.Sleep(50 * time.Millisecond)
+		case "/broken":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+}
+
+func drain(results []chan bulk.Result) {
+	for _, resultChan := range results {
+		if result := <-resultChan; result.Err() == nil {
+			result.Res().Body.Close()
+		}
+	}
+}
+
+// Tests that successful, slow and failed requests are logged with their respective levels.
+func Test_Logger_Levels(t *testing.T) {
+	// given
+	server := newLoggingServer()
+	defer server.Close()
+
+	handler := &recordingLogHandler{}
+	executor := bulk.NewExecutor(bulk.Logger(handler,
+		bulk.LoggerSlowThreshold(25*time.Millisecond),
+		bulk.LoggerLevels(bulk.LogLevelInfo, bulk.LogLevelWarn, bulk.LogLevelError),
+	))
+	defer executor.Close()
+
+	// when
+	drain(executor.AddRequests(context.Background(), server.URL+"/ok", server.URL+"/slow", server.URL+"/broken"))
+
+	// then
+	expected := map[string]bulk.LogLevel{
+		"/ok":     bulk.LogLevelInfo,
+		"/slow":   bulk.LogLevelWarn,
+		"/broken": bulk.LogLevelError,
+	}
+
+	for path, level := range expected {
+		records := handler.byPath(server, path)
+		if len(records) != 1 {
+			t.Fatalf("%s: expected 1 record, got %d", path, len(records))
+		}
+
+		if records[0].Level != level || records[0].Method != http.MethodGet || records[0].Attempts != 1 {
+			t.Errorf("%s: unexpected record %+v", path, records[0])
+		}
+	}
+
+	if slow := handler.byPath(server, "/slow")[0]; !slow.Slow || slow.Duration < 25*time.Millisecond {
+		t.Errorf("expected slow record, got %+v", slow)
+	}
+
+	if broken := handler.byPath(server, "/broken")[0]; broken.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected status code %d, got %d", http.StatusBadGateway, broken.StatusCode)
+	}
+}
+
+// Tests that only a fraction of successful requests is logged, while failures always are.
+func Test_Logger_Sampling(t *testing.T) {
+	// given
+	server := newLoggingServer()
+	defer server.Close()
+
+	handler := &recordingLogHandler{}
+	executor := bulk.NewExecutor(bulk.Logger(handler, bulk.LoggerSampleRate(0.25)))
+	defer executor.Close()
+
+	urls := make([]string, 0, 10)
+	for i := 0; i < 8; i++ {
+		urls = append(urls, server.URL+"/ok")
+	}
+	urls = append(urls, server.URL+"/broken", server.URL+"/broken")
+
+	// when
+	drain(executor.AddRequests(context.Background(), urls...))
+
+	// then
+	if ok := handler.byPath(server, "/ok"); len(ok) != 2 {
+		t.Errorf("expected 2 sampled records, got %d", len(ok))
+	}
+
+	if broken := handler.byPath(server, "/broken"); len(broken) != 2 {
+		t.Errorf("expected 2 failure records, got %d", len(broken))
+	}
+}
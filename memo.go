@@ -0,0 +1,172 @@
+package bulk
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoContextKey struct{}
+
+// memo holds the requests in flight (or finished) within the lifetime of a context.
+type memo struct {
+	mutex   sync.Mutex
+	flights map[string]*memoFlight
+}
+
+// memoFlight is a memoized request, which is shared by all of its callers.
+type memoFlight struct {
+	future  *Future
+	cancel  context.CancelFunc
+	done    chan struct{}
+	result  Result
+	waiters int
+}
+
+// WithMemo returns a context, which memoizes futures issued via the Future
+// methods of an Executor (e.g. AddFutureRequests). For the lifetime of the
+// returned context, identical requests - that is, equal method, url and
+// headers after applying the interceptor - are issued only once, and all
+// callers receive the very same *Future. As the response is shared as well, its
+// body should only be read via Future.UnmarshalResponse.
+//
+// A memoized request is only cancelled once the contexts of all callers sharing
+// it are done - so the deadline of a single caller does not affect the others,
+// and is not enforced while others still wait for the request. A request
+// cancelled that way is forgotten, so later callers issue it anew. Also, as the
+// Result is shared, its Key is the one of the first caller. Requests carrying a
+// body, or methods other than GET and HEAD, are never memoized, and are executed
+// with the context of the caller as usual.
+//
+// This is intended to be used with the context of an incoming request, so
+// multiple components can share upstream resources without any global
+// cache invalidation concerns.
+func WithMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, memoContextKey{}, &memo{flights: map[string]*memoFlight{}})
+}
+
+func memoFromContext(ctx context.Context) *memo {
+	m, _ := ctx.Value(memoContextKey{}).(*memo)
+	return m
+}
+
+func memoizable(req *http.Request) bool {
+	return req.Body == nil && (req.Method == http.MethodGet || req.Method == http.MethodHead)
+}
+
+// future returns the memoized future for the (memoizable) request, or creates (and
+// memoizes) a new one via dispatch. The caller is registered as waiting for it, and
+// the request is finished within the batch of the caller (if any) once it is done.
+func (m *memo) future(
+	ctx context.Context,
+	req *http.Request,
+	batch *batchSpan,
+	dispatch func(ctx context.Context) Result,
+) *Future {
+	key := memoKey(req)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	flight, ok := m.flights[key]
+	if !ok {
+		// the request outlives the cancellation of single callers, but keeps their values (e.g. spans)
+		flightCtx, cancel := context.WithCancel(detachedContext{Context: ctx})
+		flight = &memoFlight{
+			future: &Future{resultChan: make(chan Result, 1)},
+			cancel: cancel,
+			done:   make(chan struct{}),
+		}
+		m.flights[key] = flight
+
+		go func() {
+			flight.result = dispatch(flightCtx)
+			flight.future.resultChan <- flight.result
+			close(flight.done)
+		}()
+	}
+
+	flight.waiters++
+	go func() {
+		select {
+		case <-flight.done:
+		case <-ctx.Done():
+			m.leave(key, flight)
+		}
+	}()
+
+	if batch != nil {
+		// the shared future belongs to its callers, so the result is tapped via the flight
+		go func() {
+			<-flight.done
+			batch.finish(flight.result)
+		}()
+	}
+
+	return flight.future
+}
+
+// leave unregisters a caller, whose context is done. If it was the last one
+// waiting for the request, the request is cancelled and forgotten.
+func (m *memo) leave(key string, flight *memoFlight) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	flight.waiters--
+	if flight.waiters > 0 {
+		return
+	}
+
+	select {
+	case <-flight.done:
+		return
+	default:
+	}
+
+	if m.flights[key] == flight {
+		delete(m.flights, key)
+	}
+	flight.cancel()
+}
+
+// detachedContext carries the values of its parent, but not its cancellation.
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func memoKey(req *http.Request) string {
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	builder.WriteString(req.Method)
+	builder.WriteString(" ")
+	builder.WriteString(req.URL.String())
+
+	for _, name := range names {
+		builder.WriteString("\n")
+		builder.WriteString(name)
+		builder.WriteString(": ")
+		builder.WriteString(strings.Join(req.Header[name], ", "))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,154 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that futures are shared for identical requests within a memo context only.
+func Test_WithMemo(t *testing.T) {
+	// given
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`{"someInt":4}`))
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	ctx := bulk.WithMemo(context.Background())
+
+	// when
+	first := executor.AddFutureRequests(ctx, server.URL)[0]
+	second := executor.AddKeyedFutureRequests(ctx, map[string]string{"key": server.URL})["key"]
+	head := executor.AddFutureRequestsWithInterceptor(ctx, func(r *http.Request) error {
+		r.Method = http.MethodHead
+		return nil
+	}, server.URL)[0]
+	unmemoized := executor.AddFutureRequests(context.Background(), server.URL)[0]
+
+	// then
+	if first != second {
+		t.Error("identical requests did not share a future")
+	}
+
+	if first == head || first == unmemoized {
+		t.Error("different requests shared a future")
+	}
+
+	var firstObj, secondObj struct {
+		SomeInt int `json:"someInt"`
+	}
+	if err := first.UnmarshalResponse(&firstObj); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if err := second.UnmarshalResponse(&secondObj); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if firstObj.SomeInt != 4 || secondObj.SomeInt != 4 {
+		t.Errorf("unexpected responses %+v and %+v", firstObj, secondObj)
+	}
+
+	if err := bulk.WaitAll(head, unmemoized); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if hits := atomic.LoadInt32(&hits); hits != 3 {
+		t.Errorf("expected 3 requests to the server, got %d", hits)
+	}
+}
+
+// Tests that the deadline of callers is honored within a memo context, for memoized and unmemoized requests.
+func Test_WithMemo_CallerDeadline(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(500 * time.Millisecond):
+		}
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	memoCtx := bulk.WithMemo(context.Background())
+	ctx, cancel := context.WithTimeout(memoCtx, 50*time.Millisecond)
+	defer cancel()
+
+	// when
+	start := time.Now()
+	get := executor.AddFutureRequests(ctx, server.URL)[0]
+	post := executor.AddFutureRequestsWithInterceptor(ctx, func(r *http.Request) error {
+		r.Method = http.MethodPost
+		r.Body = ioutil.NopCloser(strings.NewReader("payload"))
+		return nil
+	}, server.URL)[0]
+
+	getErr, postErr := get.Get().Err(), post.Get().Err()
+	elapsed := time.Since(start)
+
+	// and when
+	retryCtx, retryCancel := context.WithTimeout(memoCtx, 10*time.Millisecond)
+	defer retryCancel()
+
+	retried := executor.AddFutureRequests(retryCtx, server.URL)[0]
+	retried.Get()
+
+	// then
+	if getErr == nil || postErr == nil {
+		t.Errorf("expected deadline errors, got %v and %v", getErr, postErr)
+	}
+
+	if elapsed >= 400*time.Millisecond {
+		t.Errorf("expected the deadline to be honored, took %s", elapsed)
+	}
+
+	if retried == get {
+		t.Error("expected the cancelled request to be forgotten")
+	}
+}
+
+// Tests that a memoized request is not cancelled by a single caller, while others still wait for it.
+func Test_WithMemo_SharedDeadline(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte(`{"someInt":4}`))
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	memoCtx := bulk.WithMemo(context.Background())
+	ctx, cancel := context.WithTimeout(memoCtx, 20*time.Millisecond)
+	defer cancel()
+
+	// when
+	impatient := executor.AddFutureRequests(ctx, server.URL)[0]
+	patient := executor.AddFutureRequests(memoCtx, server.URL)[0]
+
+	// then
+	if impatient != patient {
+		t.Fatal("identical requests did not share a future")
+	}
+
+	var obj struct {
+		SomeInt int `json:"someInt"`
+	}
+	if err := patient.UnmarshalResponse(&obj); err != nil || obj.SomeInt != 4 {
+		t.Errorf("expected shared request to succeed, got %v", err)
+	}
+}
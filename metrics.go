@@ -0,0 +1,254 @@
+package bulk
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestEvent describes a single request, as passed to a Metrics hook. Depending on
+// the event, not all fields are set yet (e.g. Duration is only known once finished).
+type RequestEvent struct {
+	Host   string
+	Method string
+
+	// StatusClass is the class of the response status code (e.g. "2xx"),
+	// or "error" if no response was received.
+	StatusClass string
+
+	// QueueWait is the time the request waited for a slot of the concurrency limit.
+	QueueWait time.Duration
+	Duration  time.Duration
+	Err       error
+}
+
+// Metrics receives events for requests issued by an Executor. Requests served from the
+// cache, or by joining an identical in-flight request, never reach the upstream server,
+// and are not reported. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// RequestQueued is called once a request waits for a slot of the concurrency limit.
+	RequestQueued(event RequestEvent)
+	// RequestStarted is called once a request is actually sent.
+	RequestStarted(event RequestEvent)
+	// RequestFinished is called once a response (or an error) was received.
+	RequestFinished(event RequestEvent)
+	// RequestRetried is called for each repeated attempt of a request. The Executor
+	// itself does not retry, but components retrying on top of it may report here.
+	RequestRetried(event RequestEvent)
+}
+
+func statusClass(result Result) string {
+	if result.err != nil || result.res == nil {
+		return "error"
+	}
+
+	return strconv.Itoa(result.res.StatusCode/100) + "xx"
+}
+
+// DefaultMetricsBuckets are the default histogram buckets (in seconds) of a MetricsCollector.
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsCollector is a Metrics implementation, which collects counters, gauges and
+// histograms, and exposes them in the Prometheus text format via ServeHTTP.
+type MetricsCollector struct {
+	queuedTotal   *metricFamily
+	startedTotal  *metricFamily
+	finishedTotal *metricFamily
+	retriedTotal  *metricFamily
+	queued        *metricFamily
+	inFlight      *metricFamily
+	queueWait     *metricFamily
+	duration      *metricFamily
+}
+
+// NewMetricsCollector instantiates a new MetricsCollector, using the given histogram
+// buckets (in seconds). If no buckets are given, DefaultMetricsBuckets are used.
+func NewMetricsCollector(buckets ...float64) *MetricsCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	hostMethod := []string{"host", "method"}
+	hostMethodStatus := []string{"host", "method", "status_class"}
+
+	return &MetricsCollector{
+		queuedTotal:   newMetricFamily("httpbulk_requests_queued_total", "Total number of queued requests.", "counter", hostMethod, nil),
+		startedTotal:  newMetricFamily("httpbulk_requests_started_total", "Total number of started requests.", "counter", hostMethod, nil),
+		finishedTotal: newMetricFamily("httpbulk_requests_finished_total", "Total number of finished requests.", "counter", hostMethodStatus, nil),
+		retriedTotal:  newMetricFamily("httpbulk_requests_retried_total", "Total number of retried requests.", "counter", hostMethod, nil),
+		queued:        newMetricFamily("httpbulk_requests_queued", "Number of requests waiting for a slot of the concurrency limit.", "gauge", hostMethod, nil),
+		inFlight:      newMetricFamily("httpbulk_requests_in_flight", "Number of requests currently in flight.", "gauge", hostMethod, nil),
+		queueWait:     newMetricFamily("httpbulk_request_queue_wait_seconds", "Time requests waited for a slot of the concurrency limit.", "histogram", hostMethod, sorted),
+		duration:      newMetricFamily("httpbulk_request_duration_seconds", "Duration of requests.", "histogram", hostMethodStatus, sorted),
+	}
+}
+
+// RequestQueued implements Metrics.
+func (c *MetricsCollector) RequestQueued(event RequestEvent) {
+	c.queuedTotal.add(1, event.Host, event.Method)
+	c.queued.add(1, event.Host, event.Method)
+}
+
+// RequestStarted implements Metrics.
+func (c *MetricsCollector) RequestStarted(event RequestEvent) {
+	c.startedTotal.add(1, event.Host, event.Method)
+	c.queued.add(-1, event.Host, event.Method)
+	c.inFlight.add(1, event.Host, event.Method)
+	c.queueWait.observe(event.QueueWait.Seconds(), event.Host, event.Method)
+}
+
+// RequestFinished implements Metrics.
+func (c *MetricsCollector) RequestFinished(event RequestEvent) {
+	c.finishedTotal.add(1, event.Host, event.Method, event.StatusClass)
+	c.inFlight.add(-1, event.Host, event.Method)
+	c.duration.observe(event.Duration.Seconds(), event.Host, event.Method, event.StatusClass)
+}
+
+// RequestRetried implements Metrics.
+func (c *MetricsCollector) RequestRetried(event RequestEvent) {
+	c.retriedTotal.add(1, event.Host, event.Method)
+}
+
+// ServeHTTP exposes all collected metrics in the Prometheus text format.
+func (c *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var builder strings.Builder
+	for _, family := range []*metricFamily{
+		c.queuedTotal, c.startedTotal, c.finishedTotal, c.retriedTotal,
+		c.queued, c.inFlight, c.queueWait, c.duration,
+	} {
+		family.write(&builder)
+	}
+
+	w.Write([]byte(builder.String()))
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*metricSeries
+}
+
+type metricSeries struct {
+	labelValues []string
+
+	// counters and gauges
+	value float64
+
+	// histograms
+	bucketCounts []uint64
+	sum          float64
+	count        uint64
+}
+
+func newMetricFamily(name, help, typ string, labels []string, buckets []float64) *metricFamily {
+	return &metricFamily{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*metricSeries{},
+	}
+}
+
+// get returns the series for the label values. The mutex must be held.
+func (f *metricFamily) get(labelValues []string) *metricSeries {
+	key := strings.Join(labelValues, "\x00")
+
+	series, ok := f.series[key]
+	if !ok {
+		series = &metricSeries{labelValues: labelValues, bucketCounts: make([]uint64, len(f.buckets))}
+		f.series[key] = series
+	}
+
+	return series
+}
+
+func (f *metricFamily) add(delta float64, labelValues ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.get(labelValues).value += delta
+}
+
+func (f *metricFamily) observe(value float64, labelValues ...string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	series := f.get(labelValues)
+	for i, bound := range f.buckets {
+		if value <= bound {
+			series.bucketCounts[i]++
+		}
+	}
+	series.sum += value
+	series.count++
+}
+
+func (f *metricFamily) write(builder *strings.Builder) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	fmt.Fprintf(builder, "# HELP %s %s\n", f.name, f.help)
+	fmt.Fprintf(builder, "# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		series := f.series[key]
+		labels := f.formatLabels(series.labelValues, "")
+
+		if f.typ != "histogram" {
+			fmt.Fprintf(builder, "%s%s %s\n", f.name, labels, formatMetricValue(series.value))
+			continue
+		}
+
+		for i, bound := range f.buckets {
+			le := f.formatLabels(series.labelValues, formatMetricValue(bound))
+			fmt.Fprintf(builder, "%s_bucket%s %d\n", f.name, le, series.bucketCounts[i])
+		}
+		fmt.Fprintf(builder, "%s_bucket%s %d\n", f.name, f.formatLabels(series.labelValues, "+Inf"), series.count)
+		fmt.Fprintf(builder, "%s_sum%s %s\n", f.name, labels, formatMetricValue(series.sum))
+		fmt.Fprintf(builder, "%s_count%s %d\n", f.name, labels, series.count)
+	}
+}
+
+func (f *metricFamily) formatLabels(labelValues []string, le string) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, value := range labelValues {
+		pairs = append(pairs, f.labels[i]+`="`+escapeLabelValue(value)+`"`)
+	}
+
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
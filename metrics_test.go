@@ -0,0 +1,78 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Tests that the MetricsCollector records requests, and exposes them in the Prometheus text format.
+func Test_MetricsCollector(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	collector := bulk.NewMetricsCollector(0.5, 1)
+	executor := bulk.NewExecutor(bulk.CollectMetrics(collector))
+	defer executor.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// when
+	for _, resultChan := range executor.AddRequests(context.Background(), server.URL+"/a", server.URL+"/b", server.URL+"/missing") {
+		if result := <-resultChan; result.Err() == nil {
+			result.Res().Body.Close()
+		}
+	}
+	collector.RequestRetried(bulk.RequestEvent{Host: host, Method: http.MethodGet})
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := ioutil.ReadAll(recorder.Body)
+
+	// then
+	expected := []string{
+		`# TYPE httpbulk_requests_finished_total counter`,
+		`httpbulk_requests_queued_total{host="` + host + `",method="GET"} 3`,
+		`httpbulk_requests_finished_total{host="` + host + `",method="GET",status_class="2xx"} 2`,
+		`httpbulk_requests_finished_total{host="` + host + `",method="GET",status_class="4xx"} 1`,
+		`httpbulk_requests_retried_total{host="` + host + `",method="GET"} 1`,
+		`httpbulk_requests_in_flight{host="` + host + `",method="GET"} 0`,
+		`httpbulk_request_duration_seconds_bucket{host="` + host + `",method="GET",status_class="2xx",le="+Inf"} 2`,
+		`httpbulk_request_duration_seconds_count{host="` + host + `",method="GET",status_class="4xx"} 1`,
+		`httpbulk_request_queue_wait_seconds_count{host="` + host + `",method="GET"} 3`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+// Tests that label values are escaped in the Prometheus text format.
+func Test_MetricsCollector_Escaping(t *testing.T) {
+	// given
+	collector := bulk.NewMetricsCollector()
+
+	// when
+	collector.RequestRetried(bulk.RequestEvent{Host: "a\"b\\c\nd", Method: http.MethodGet})
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// then
+	expected := `httpbulk_requests_retried_total{host="a\"b\\c\nd",method="GET"} 1`
+	if !strings.Contains(recorder.Body.String(), expected) {
+		t.Errorf("expected line %q in:\n%s", expected, recorder.Body.String())
+	}
+}
@@ -0,0 +1,224 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidBatchResponse = errors.New("invalid multipart batch response")
+	ErrMissingBatchPart     = errors.New("missing part in multipart batch response")
+)
+
+// MultipartBatchOptions is the option-wrapper for defining the workings of AddMultipartBatch.
+type MultipartBatchOptions struct {
+	MaxBatchSize  int
+	ModifyRequest func(r *http.Request) error
+}
+
+type MultipartBatchOption func(*MultipartBatchOptions)
+
+// MultipartMaxBatchSize sets the maximum amount of sub-requests per batch. Larger amounts
+// of requests are split into multiple batches. Per default, 100 sub-requests are used.
+// You can use -1 to indicate no limit.
+func MultipartMaxBatchSize(size int) MultipartBatchOption {
+	return func(args *MultipartBatchOptions) {
+		args.MaxBatchSize = size
+	}
+}
+
+// MultipartInterceptor sets a hook for modifying each batch request prior to sending.
+func MultipartInterceptor(modifyRequest func(r *http.Request) error) MultipartBatchOption {
+	return func(args *MultipartBatchOptions) {
+		args.ModifyRequest = modifyRequest
+	}
+}
+
+// AddMultipartBatch packs the given requests into multipart/mixed batch requests (as used by
+// OData or Google-style batch endpoints), and POSTs them to the given batch url. The multipart
+// response is parsed back into an individual Result per request, carrying its own status,
+// headers and body. Parts are correlated via their Content-ID, and by order otherwise.
+//
+// If a batch fails as a whole, all of its Results carry the same error. Note, that the bodies
+// of the given requests are consumed.
+func AddMultipartBatch(
+	ctx context.Context,
+	executor Requester,
+	batchURL string,
+	requests []*http.Request,
+	setters ...MultipartBatchOption,
+) []chan Result {
+	// Default Options
+	args := &MultipartBatchOptions{
+		MaxBatchSize: 100,
+	}
+
+	for _, setter := range setters {
+		setter(args)
+	}
+
+	results := make([]chan Result, len(requests))
+	for i := range requests {
+		results[i] = make(chan Result, 1)
+	}
+
+	chunkSize := args.MaxBatchSize
+	if chunkSize <= 0 {
+		chunkSize = len(requests)
+	}
+
+	for start := 0; start < len(requests); start += chunkSize {
+		end := start + chunkSize
+		if end > len(requests) {
+			end = len(requests)
+		}
+
+		go executeMultipartBatch(ctx, executor, batchURL, requests[start:end], results[start:end], args.ModifyRequest)
+	}
+
+	return results
+}
+
+func executeMultipartBatch(
+	ctx context.Context,
+	executor Requester,
+	batchURL string,
+	requests []*http.Request,
+	results []chan Result,
+	modifyRequest func(r *http.Request) error,
+) {
+	ctx, batch := startRequesterBatch(ctx, executor, "multipart batch", len(requests))
+
+	fail := func(err error) {
+		batch.end(err)
+		for i, req := range requests {
+			results[i] <- Result{url: req.URL.String(), err: err}
+		}
+	}
+
+	payload, contentType, err := encodeMultipartBatch(requests)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	result := <-addRequest(ctx, executor, postInterceptor(contentType, payload, modifyRequest), "", batchURL)
+	if result.Err() != nil {
+		fail(result.Err())
+		return
+	}
+
+	responses, err := decodeMultipartBatch(result.res, requests)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	batch.end(nil)
+	for i, req := range requests {
+		if responses[i] == nil {
+			results[i] <- Result{url: req.URL.String(), dur: result.dur, err: fmt.Errorf("part %d: %w", i, ErrMissingBatchPart)}
+			continue
+		}
+
+		results[i] <- Result{url: req.URL.String(), res: responses[i], dur: result.dur}
+	}
+}
+
+func multipartContentID(index int) string {
+	return "<bulk-" + strconv.Itoa(index) + ">"
+}
+
+func encodeMultipartBatch(requests []*http.Request) ([]byte, string, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	for i, req := range requests {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"application/http"},
+			"Content-Transfer-Encoding": {"binary"},
+			"Content-Id":                {multipartContentID(i)},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+
+		if err := req.Write(part); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buffer.Bytes(), "multipart/mixed; boundary=" + writer.Boundary(), nil
+}
+
+func decodeMultipartBatch(res *http.Response, requests []*http.Request) ([]*http.Response, error) {
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status %d: %w", res.StatusCode, ErrRequestFailed)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, fmt.Errorf("content type %q: %w", res.Header.Get("Content-Type"), ErrInvalidBatchResponse)
+	}
+
+	contentIDs := make(map[string]int, len(requests))
+	for i := range requests {
+		id := multipartContentID(i)
+		contentIDs[id] = i
+		contentIDs["<response-"+id[1:]] = i
+	}
+
+	responses := make([]*http.Response, len(requests))
+	reader := multipart.NewReader(res.Body, params["boundary"])
+	for position := 0; ; position++ {
+		part, err := reader.NextPart()
+		if err != nil {
+			if err == io.EOF {
+				return responses, nil
+			}
+
+			return nil, fmt.Errorf("%s: %w", err, ErrInvalidBatchResponse)
+		}
+
+		index, ok := contentIDs[part.Header.Get("Content-Id")]
+		if !ok {
+			index = position
+		}
+
+		if index >= len(requests) {
+			return nil, fmt.Errorf("unexpected part %d: %w", position, ErrInvalidBatchResponse)
+		}
+
+		partRes, err := http.ReadResponse(bufio.NewReader(part), requests[index])
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %s: %w", position, err, ErrInvalidBatchResponse)
+		}
+
+		body, err := ioutil.ReadAll(partRes.Body)
+		partRes.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		partRes.Body = ioutil.NopCloser(bytes.NewReader(body))
+		partRes.ContentLength = int64(len(body))
+		responses[index] = partRes
+	}
+}
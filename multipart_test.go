@@ -0,0 +1,166 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// batchHandler answers each sub-request with its path, in reverse order.
+func batchHandler(t *testing.T, batches *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(batches, 1)
+
+		if r.Method != http.MethodPost {
+			t.Errorf("expected POST batch request, got %s", r.Method)
+		}
+
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Errorf("unexpected error %s", err)
+			return
+		}
+
+		type subRequest struct {
+			contentID string
+			path      string
+			body      string
+		}
+
+		var subRequests []subRequest
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+
+			req, err := http.ReadRequest(bufio.NewReader(part))
+			if err != nil {
+				t.Errorf("unexpected error %s", err)
+				return
+			}
+
+			body, _ := ioutil.ReadAll(req.Body)
+			subRequests = append(subRequests, subRequest{
+				contentID: part.Header.Get("Content-Id"),
+				path:      req.URL.Path,
+				body:      string(body),
+			})
+		}
+
+		writer := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+
+		for i := len(subRequests) - 1; i >= 0; i-- {
+			sub := subRequests[i]
+			part, _ := writer.CreatePart(textproto.MIMEHeader{
+				"Content-Type": {"application/http"},
+				"Content-Id":   {"<response-" + strings.Trim(sub.contentID, "<>") + ">"},
+			})
+
+			status := http.StatusOK
+			if sub.path == "/missing" {
+				status = http.StatusNotFound
+			}
+
+			payload := sub.path + sub.body
+			fmt.Fprintf(part, "HTTP/1.1 %d %s\r\nX-Path: %s\r\nContent-Length: %d\r\n\r\n%s",
+				status, http.StatusText(status), sub.path, len(payload), payload)
+		}
+
+		writer.Close()
+	}
+}
+
+// Tests that requests are packed into (chunked) batches, and correlated with their responses.
+func Test_AddMultipartBatch(t *testing.T) {
+	// given
+	var batches int32
+	server := httptest.NewServer(batchHandler(t, &batches))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	var requests []*http.Request
+	for _, path := range []string{"/a", "/b", "/missing"} {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com"+path, nil)
+		requests = append(requests, req)
+	}
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com/c", strings.NewReader("-body"))
+	requests = append(requests, post)
+
+	// when
+	results := bulk.AddMultipartBatch(context.Background(), executor, server.URL+"/batch", requests,
+		bulk.MultipartMaxBatchSize(3),
+	)
+
+	// then
+	expected := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusOK, "/a"},
+		{http.StatusOK, "/b"},
+		{http.StatusNotFound, "/missing"},
+		{http.StatusOK, "/c-body"},
+	}
+
+	for i, resultChan := range results {
+		result := <-resultChan
+		if result.Err() != nil {
+			t.Fatalf("result %d: unexpected error %s", i, result.Err())
+		}
+
+		body, _ := ioutil.ReadAll(result.Res().Body)
+		if result.Res().StatusCode != expected[i].status || string(body) != expected[i].body {
+			t.Errorf("result %d: expected %d %q, got %d %q", i, expected[i].status, expected[i].body, result.Res().StatusCode, body)
+		}
+
+		if result.URL() != requests[i].URL.String() {
+			t.Errorf("result %d: unexpected url %s", i, result.URL())
+		}
+	}
+
+	if batches := atomic.LoadInt32(&batches); batches != 2 {
+		t.Errorf("expected 2 batches, got %d", batches)
+	}
+}
+
+// Tests that a failing batch fails all of its results.
+func Test_AddMultipartBatch_Failure(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	first, _ := http.NewRequest(http.MethodGet, "https://example.com/a", nil)
+	second, _ := http.NewRequest(http.MethodGet, "https://example.com/b", nil)
+
+	// when
+	results := bulk.AddMultipartBatch(context.Background(), executor, server.URL, []*http.Request{first, second})
+
+	// then
+	for i, resultChan := range results {
+		if err := (<-resultChan).Err(); !errors.Is(err, bulk.ErrRequestFailed) {
+			t.Errorf("result %d: expected request failed error, got %v", i, err)
+		}
+	}
+}
@@ -1,11 +1,33 @@
 package bulk
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 // Options is the option-wrapper for defining the workings of an Executor
 type Options struct {
 	ConcurrencyLimit int
 	Client           *http.Client
+	ValidatorStore   ValidatorStore
+	CacheStorage     CacheStorage
+	PrivateCache     bool
+
+	Deduplicate        bool
+	DeduplicateHeaders []string
+
+	Metrics Metrics
+
+	LogHandler LogHandler
+	LogOptions LoggerOptions
+
+	Tracer Tracer
+
+	Watchdog *WatchdogOptions
+
+	ReadLimits ReadLimits
+
+	HARRecorder *HARRecorder
 }
 
 type Option func(*Options)
@@ -27,3 +49,122 @@ func Client(client *http.Client) Option {
 		args.Client = client
 	}
 }
+
+// ConditionalRequests enables conditional GET requests. The ETag and Last-Modified
+// headers of responses are remembered per url in the given store, and sent as
+// If-None-Match and If-Modified-Since headers on subsequent requests. If the server
+// responds with 304 Not Modified, the stored response is served instead, and the
+// Result is marked as revalidated. Per default, no store is used.
+func ConditionalRequests(store ValidatorStore) Option {
+	return func(args *Options) {
+		args.ValidatorStore = store
+	}
+}
+
+// Cache enables caching of responses as defined in RFC 7234, honoring Cache-Control
+// (including max-age, s-maxage, no-store, private, stale-while-revalidate and
+// stale-if-error), Expires and Vary headers. Responses without explicit freshness
+// information are not cached. Cache hits are marked on the Result, and do not
+// occupy a slot of the concurrency limit. Per default, no cache is used.
+func Cache(storage CacheStorage) Option {
+	return func(args *Options) {
+		args.CacheStorage = storage
+	}
+}
+
+// PrivateCache regulates whether the cache acts as a private cache (i.e. of a single
+// user), or a shared one. Per default, the cache is shared - that is, responses marked
+// as private, or requested with an Authorization header, are not cached.
+func PrivateCache(private bool) Option {
+	return func(args *Options) {
+		args.PrivateCache = private
+	}
+}
+
+// Deduplicate collapses identical in-flight GET and HEAD requests into a single
+// upstream request. Requests are considered identical, if their method, url and
+// the values of the given headers are equal. Each caller receives a Result with
+// an independently readable copy of the (then buffered) body. Note, that followers
+// share the outcome of the first request - including a cancellation of its context.
+// Per default, requests are not deduplicated.
+func Deduplicate(headers ...string) Option {
+	return func(args *Options) {
+		args.Deduplicate = true
+		args.DeduplicateHeaders = headers
+	}
+}
+
+// CollectMetrics sets a hook, which receives events for all requests issued by the
+// Executor (see Metrics). Per default, no metrics are collected.
+func CollectMetrics(metrics Metrics) Option {
+	return func(args *Options) {
+		args.Metrics = metrics
+	}
+}
+
+// Logger sets a handler, which receives a structured record for each request issued
+// by the Executor (see LogRecord). Use LoggerOption setters for configuring levels,
+// a slow-request threshold and sampling. Per default, no records are emitted.
+func Logger(handler LogHandler, setters ...LoggerOption) Option {
+	return func(args *Options) {
+		args.LogHandler = handler
+
+		// Default Options
+		args.LogOptions = LoggerOptions{
+			SuccessLevel: LogLevelDebug,
+			SlowLevel:    LogLevelWarn,
+			FailureLevel: LogLevelError,
+			SampleRate:   1,
+		}
+
+		for _, setter := range setters {
+			setter(&args.LogOptions)
+		}
+	}
+}
+
+// Tracing opens a span (via the given tracer) for each request issued by the Executor,
+// as well as for each batch of requests - such as the urls of a single AddRequests call.
+// The W3C traceparent and tracestate headers are injected into each request, and the
+// status and error of each request are recorded on its span. Per default, no spans are
+// opened, and no headers are injected.
+func Tracing(tracer Tracer) Option {
+	return func(args *Options) {
+		args.Tracer = tracer
+	}
+}
+
+// Watchdog enables a watchdog, which periodically scans in-flight requests. Requests
+// without a response after the given threshold are considered stuck - as well as
+// response bodies without any read progress for the body idle timeout (if configured).
+// Use WatchdogOption setters for reporting and cancelling stuck requests. Per default,
+// no watchdog is used.
+func Watchdog(threshold time.Duration, setters ...WatchdogOption) Option {
+	return func(args *Options) {
+		args.Watchdog = &WatchdogOptions{
+			Threshold: threshold,
+		}
+
+		for _, setter := range setters {
+			setter(args.Watchdog)
+		}
+	}
+}
+
+// BodyReadLimits sets the limits for reading response bodies, such as an idle timeout
+// and a minimum throughput (see ReadLimits). They can be overridden per request via
+// WithReadLimits. Per default, no limits are enforced.
+func BodyReadLimits(limits ReadLimits) Option {
+	return func(args *Options) {
+		args.ReadLimits = limits
+	}
+}
+
+// RecordHAR records all requests issued by the Executor, including their responses,
+// timings and errors, with the given recorder (see HARRecorder). Per default, no
+// requests are recorded.
+func RecordHAR(recorder *HARRecorder) Option {
+	return func(args *Options) {
+		args.HARRecorder = recorder
+	}
+}
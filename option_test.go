@@ -3,8 +3,10 @@ package bulk_test
 import (
 	bulk "github.com/kernle32dll/httpbulk-go"
 
+	"context"
 	"net/http"
 	"testing"
+	"time"
 )
 
 // Tests that the ConcurrencyLimit option correctly applies.
@@ -36,3 +38,171 @@ func Test_Option_Client(t *testing.T) {
 		t.Error("client not correctly applied")
 	}
 }
+
+// Tests that the ConditionalRequests option correctly applies.
+func Test_Option_ConditionalRequests(t *testing.T) {
+	// given
+	store := bulk.NewMemoryValidatorStore()
+	option := bulk.ConditionalRequests(store)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.ValidatorStore != store {
+		t.Error("validator store not correctly applied")
+	}
+}
+
+// Tests that the Cache option correctly applies.
+func Test_Option_Cache(t *testing.T) {
+	// given
+	storage := bulk.NewMemoryCacheStorage(1024)
+	option := bulk.Cache(storage)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.CacheStorage != storage {
+		t.Error("cache storage not correctly applied")
+	}
+}
+
+// Tests that the PrivateCache option correctly applies.
+func Test_Option_PrivateCache(t *testing.T) {
+	// given
+	option := bulk.PrivateCache(true)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if !options.PrivateCache {
+		t.Error("private cache not correctly applied")
+	}
+}
+
+// Tests that the Deduplicate option correctly applies.
+func Test_Option_Deduplicate(t *testing.T) {
+	// given
+	option := bulk.Deduplicate("Accept-Language")
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if !options.Deduplicate || len(options.DeduplicateHeaders) != 1 || options.DeduplicateHeaders[0] != "Accept-Language" {
+		t.Error("deduplication not correctly applied")
+	}
+}
+
+// Tests that the CollectMetrics option correctly applies.
+func Test_Option_CollectMetrics(t *testing.T) {
+	// given
+	collector := bulk.NewMetricsCollector()
+	option := bulk.CollectMetrics(collector)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.Metrics != collector {
+		t.Error("metrics not correctly applied")
+	}
+}
+
+// Tests that the Logger option correctly applies, including its defaults.
+func Test_Option_Logger(t *testing.T) {
+	// given
+	handler := bulk.LogHandlerFunc(func(ctx context.Context, record bulk.LogRecord) {})
+	option := bulk.Logger(handler, bulk.LoggerSlowThreshold(time.Second))
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.LogHandler == nil {
+		t.Error("log handler not correctly applied")
+	}
+
+	expected := bulk.LoggerOptions{
+		SuccessLevel:  bulk.LogLevelDebug,
+		SlowLevel:     bulk.LogLevelWarn,
+		FailureLevel:  bulk.LogLevelError,
+		SlowThreshold: time.Second,
+		SampleRate:    1,
+	}
+	if options.LogOptions != expected {
+		t.Errorf("expected logger options %+v, got %+v", expected, options.LogOptions)
+	}
+}
+
+// Tests that the Tracing option correctly applies.
+func Test_Option_Tracing(t *testing.T) {
+	// given
+	tracer := &testTracer{}
+	option := bulk.Tracing(tracer)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.Tracer != tracer {
+		t.Error("tracer not correctly applied")
+	}
+}
+
+// Tests that the Watchdog option correctly applies.
+func Test_Option_Watchdog(t *testing.T) {
+	// given
+	option := bulk.Watchdog(time.Minute, bulk.WatchdogCancel(true), bulk.WatchdogBodyIdleTimeout(time.Second))
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.Watchdog == nil || options.Watchdog.Threshold != time.Minute || !options.Watchdog.Cancel || options.Watchdog.BodyIdleTimeout != time.Second {
+		t.Errorf("watchdog not correctly applied: %+v", options.Watchdog)
+	}
+}
+
+// Tests that the BodyReadLimits option correctly applies.
+func Test_Option_BodyReadLimits(t *testing.T) {
+	// given
+	limits := bulk.ReadLimits{IdleTimeout: time.Second, MinThroughput: 1024}
+	option := bulk.BodyReadLimits(limits)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.ReadLimits != limits {
+		t.Error("read limits not correctly applied")
+	}
+}
+
+// Tests that the RecordHAR option correctly applies.
+func Test_Option_RecordHAR(t *testing.T) {
+	// given
+	recorder := bulk.NewHARRecorder()
+	option := bulk.RecordHAR(recorder)
+	options := &bulk.Options{}
+
+	// when
+	option(options)
+
+	// then
+	if options.HARRecorder != recorder {
+		t.Error("har recorder not correctly applied")
+	}
+}
@@ -0,0 +1,436 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidStrategy = errors.New("invalid pagination strategy")
+)
+
+// Page is a single page fetched via Paginate. The body of the
+// response is already read, and available via Body.
+type Page struct {
+	Index  int
+	Result Result
+	Body   []byte
+
+	err error
+}
+
+// Err returns an error, if any occurred while fetching the page. Besides
+// errors of the request itself, this includes non 2xx status codes.
+func (p Page) Err() error {
+	return p.err
+}
+
+// UnmarshalResponse unmarshals the (json) body of the page into the provided
+// interface type (remember to provide a reference, not a value!).
+func (p Page) UnmarshalResponse(target interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	return json.Unmarshal(p.Body, target)
+}
+
+// PageStrategy determines the url of the page following a given one.
+type PageStrategy interface {
+	// NextURL returns the url of the page following the given one,
+	// or an empty string if the given page is the last one.
+	NextURL(page Page) (string, error)
+}
+
+// PageLister is an optional extension of PageStrategy, for strategies which
+// (possibly) know the urls of all pages after the first one has been fetched.
+// If known, all remaining pages are prefetched in parallel.
+type PageLister interface {
+	// PageURLs returns the urls of all pages following the first one. If the
+	// amount of pages cannot be determined, known must be false.
+	PageURLs(first Page) (urls []string, known bool, err error)
+}
+
+// Paginate fetches the page behind the given url, and all pages following it
+// as determined by the given strategy. Pages are sent in order to the returned
+// channel, which is closed after the last page or the first failing one. Cancel
+// the context to stop paginating early.
+func Paginate(ctx context.Context, executor Requester, firstURL string, strategy PageStrategy) <-chan Page {
+	return PaginateWithInterceptor(ctx, executor, nil, firstURL, strategy)
+}
+
+// PaginateWithInterceptor fetches pages as described in Paginate.
+// For each call, optional hooks for modifying the request are executed (if not nil).
+func PaginateWithInterceptor(
+	ctx context.Context,
+	executor Requester,
+	modifyRequest func(r *http.Request) error,
+	firstURL string,
+	strategy PageStrategy,
+) <-chan Page {
+	pages := make(chan Page)
+
+	go func() {
+		defer close(pages)
+
+		send := func(page Page) bool {
+			select {
+			case pages <- page:
+				return page.err == nil
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		page := readPage(0, <-addRequest(ctx, executor, modifyRequest, "", firstURL))
+		if !send(page) {
+			return
+		}
+
+		if lister, ok := strategy.(PageLister); ok {
+			urls, known, err := lister.PageURLs(page)
+			if err != nil {
+				send(Page{Index: 1, err: err})
+				return
+			}
+
+			if known {
+				// prefetches are cancelled, if pagination stops early
+				prefetchCtx, cancel := context.WithCancel(ctx)
+				defer cancel()
+
+				results := make([]chan Result, len(urls))
+				for i, url := range urls {
+					results[i] = addRequest(prefetchCtx, executor, modifyRequest, "", url)
+				}
+
+				for i, result := range results {
+					if !send(readPage(i+1, <-result)) {
+						go drainResults(results[i+1:])
+						return
+					}
+				}
+
+				return
+			}
+		}
+
+		for index := 1; ; index++ {
+			nextURL, err := strategy.NextURL(page)
+			if err != nil {
+				send(Page{Index: index, err: err})
+				return
+			}
+
+			if nextURL == "" {
+				return
+			}
+
+			page = readPage(index, <-addRequest(ctx, executor, modifyRequest, "", nextURL))
+			if !send(page) {
+				return
+			}
+		}
+	}()
+
+	return pages
+}
+
+// drainResults waits for all results, and closes their response bodies.
+func drainResults(results []chan Result) {
+	for _, resultChan := range results {
+		if result := <-resultChan; result.res != nil {
+			result.res.Body.Close()
+		}
+	}
+}
+
+func readPage(index int, result Result) Page {
+	page := Page{Index: index, Result: result}
+	if result.Err() != nil {
+		page.err = result.Err()
+		return page
+	}
+
+	res := result.res
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		page.err = err
+		return page
+	}
+
+	page.Body = body
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		page.err = fmt.Errorf("unexpected status %d for %s: %w", res.StatusCode, result.URL(), ErrRequestFailed)
+	}
+
+	return page
+}
+
+// LinkHeaderStrategy follows the rel="next" link of the RFC 8288 Link header.
+func LinkHeaderStrategy() PageStrategy {
+	return linkHeaderStrategy{}
+}
+
+type linkHeaderStrategy struct{}
+
+func (linkHeaderStrategy) NextURL(page Page) (string, error) {
+	for _, header := range page.Result.Res().Header.Values("Link") {
+		for _, link := range parseLinkHeader(header) {
+			if !link.hasRel("next") {
+				continue
+			}
+
+			return resolvePageURL(page.Result.URL(), link.target)
+		}
+	}
+
+	return "", nil
+}
+
+type headerLink struct {
+	target string
+	params map[string]string
+}
+
+func (l headerLink) hasRel(rel string) bool {
+	for _, value := range strings.Fields(l.params["rel"]) {
+		if strings.EqualFold(value, rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseLinkHeader parses the value of a Link header as defined in RFC 8288.
+// Malformed links are skipped.
+func parseLinkHeader(header string) []headerLink {
+	var links []headerLink
+
+	for {
+		header = strings.TrimLeft(header, " \t,")
+		if !strings.HasPrefix(header, "<") {
+			return links
+		}
+
+		end := strings.IndexByte(header, '>')
+		if end < 0 {
+			return links
+		}
+
+		link := headerLink{target: header[1:end], params: map[string]string{}}
+		header = header[end+1:]
+
+		// parameters, until the next link starts
+		for {
+			header = strings.TrimLeft(header, " \t")
+			if !strings.HasPrefix(header, ";") {
+				break
+			}
+			header = strings.TrimLeft(header[1:], " \t")
+
+			nameEnd := strings.IndexAny(header, "=;,")
+			if nameEnd < 0 {
+				link.params[strings.ToLower(strings.TrimSpace(header))] = ""
+				header = ""
+				break
+			}
+
+			name := strings.ToLower(strings.TrimSpace(header[:nameEnd]))
+			header = header[nameEnd:]
+			if header[0] != '=' {
+				link.params[name] = ""
+				continue
+			}
+			header = strings.TrimLeft(header[1:], " \t")
+
+			var value string
+			if strings.HasPrefix(header, `"`) {
+				var builder strings.Builder
+				i := 1
+				for ; i < len(header) && header[i] != '"'; i++ {
+					if header[i] == '\\' && i+1 < len(header) {
+						i++
+					}
+					builder.WriteByte(header[i])
+				}
+				value = builder.String()
+				if i < len(header) {
+					i++
+				}
+				header = header[i:]
+			} else {
+				valueEnd := strings.IndexAny(header, ";,")
+				if valueEnd < 0 {
+					valueEnd = len(header)
+				}
+				value = strings.TrimSpace(header[:valueEnd])
+				header = header[valueEnd:]
+			}
+
+			// per RFC 8288, only the first occurrence of a parameter counts
+			if _, ok := link.params[name]; !ok {
+				link.params[name] = value
+			}
+		}
+
+		links = append(links, link)
+	}
+}
+
+// JSONCursorStrategy reads a cursor from the (json) body of each page, and passes it
+// as the given query parameter to the following page. The cursor is located via a dot
+// separated path (e.g. "meta.next_cursor"). Pagination stops, if the cursor is missing,
+// null or empty.
+func JSONCursorStrategy(path string, param string) PageStrategy {
+	return jsonCursorStrategy{path: strings.Split(path, "."), param: param}
+}
+
+type jsonCursorStrategy struct {
+	path  []string
+	param string
+}
+
+func (s jsonCursorStrategy) NextURL(page Page) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(page.Body))
+	decoder.UseNumber()
+
+	var current interface{}
+	if err := decoder.Decode(&current); err != nil {
+		return "", err
+	}
+
+	for _, segment := range s.path {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return "", nil
+		}
+
+		current = object[segment]
+	}
+
+	var cursor string
+	switch value := current.(type) {
+	case string:
+		cursor = value
+	case json.Number:
+		cursor = value.String()
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("cursor at %s is of type %T: %w", strings.Join(s.path, "."), current, ErrInvalidStrategy)
+	}
+
+	if cursor == "" {
+		return "", nil
+	}
+
+	return withQueryParam(page.Result.URL(), s.param, cursor)
+}
+
+// OffsetStrategy increments a numeric query parameter for each page. Use Start 0
+// and Step set to the page size for offset based pagination, or Start 1 and Step 1
+// for page numbers. The first url is requested as given, and is expected to
+// be the page for Start.
+//
+// If Count is set, all pages are prefetched in parallel after the first one.
+// Otherwise, pages are fetched one after another, until IsLast reports true.
+// One of the two must be set.
+type OffsetStrategy struct {
+	Param string
+	Start int
+	Step  int
+
+	// Count returns the total amount of pages, as determined from the first page.
+	Count func(first Page) (int, error)
+
+	// IsLast reports whether the given page is the last one.
+	IsLast func(page Page) (bool, error)
+}
+
+// NextURL implements PageStrategy.
+func (s OffsetStrategy) NextURL(page Page) (string, error) {
+	if s.Count != nil {
+		count, err := s.Count(page)
+		if err != nil {
+			return "", err
+		}
+
+		if page.Index+1 >= count {
+			return "", nil
+		}
+	} else if s.IsLast != nil {
+		last, err := s.IsLast(page)
+		if err != nil || last {
+			return "", err
+		}
+	} else {
+		return "", fmt.Errorf("neither Count nor IsLast set: %w", ErrInvalidStrategy)
+	}
+
+	return withQueryParam(page.Result.URL(), s.Param, strconv.Itoa(s.Start+(page.Index+1)*s.Step))
+}
+
+// PageURLs implements PageLister.
+func (s OffsetStrategy) PageURLs(first Page) ([]string, bool, error) {
+	if s.Count == nil {
+		return nil, false, nil
+	}
+
+	count, err := s.Count(first)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var urls []string
+	for index := 1; index < count; index++ {
+		url, err := withQueryParam(first.Result.URL(), s.Param, strconv.Itoa(s.Start+index*s.Step))
+		if err != nil {
+			return nil, false, err
+		}
+
+		urls = append(urls, url)
+	}
+
+	return urls, true, nil
+}
+
+func resolvePageURL(base, ref string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return baseURL.ResolveReference(refURL).String(), nil
+}
+
+func withQueryParam(rawURL, param, value string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := parsed.Query()
+	query.Set(param, value)
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String(), nil
+}
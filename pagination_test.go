@@ -0,0 +1,263 @@
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Tests that parseLinkHeader correctly parses RFC 8288 Link headers.
+func Test_parseLinkHeader(t *testing.T) {
+	// when
+	links := parseLinkHeader(`<https://example.com/?page=2>; rel="next last", <./1>;rel=prev;title="a, \"b\""`)
+
+	// then
+	if len(links) != 2 {
+		t.Fatalf("expected 2 links, got %d", len(links))
+	}
+
+	if links[0].target != "https://example.com/?page=2" || !links[0].hasRel("next") || !links[0].hasRel("last") {
+		t.Errorf("first link not correctly parsed: %+v", links[0])
+	}
+
+	expectedParams := map[string]string{"rel": "prev", "title": `a, "b"`}
+	if links[1].target != "./1" || !reflect.DeepEqual(links[1].params, expectedParams) {
+		t.Errorf("second link not correctly parsed: %+v", links[1])
+	}
+}
+
+func collectPages(t *testing.T, pages <-chan Page) []string {
+	var bodies []string
+	for page := range pages {
+		if page.Err() != nil {
+			t.Fatalf("unexpected error for page %d: %s", page.Index, page.Err())
+		}
+
+		if page.Index != len(bodies) {
+			t.Errorf("expected page index %d, got %d", len(bodies), page.Index)
+		}
+
+		bodies = append(bodies, string(page.Body))
+	}
+
+	return bodies
+}
+
+// Tests that Paginate follows Link headers.
+func Test_Paginate_LinkHeader(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			w.Header().Set("Link", fmt.Sprintf(`</items?page=%d>; rel="next"`, page+1))
+		}
+		fmt.Fprintf(w, "page %d", page)
+	}))
+	defer server.Close()
+
+	executor := NewExecutor()
+	defer executor.Close()
+
+	// when
+	bodies := collectPages(t, Paginate(context.Background(), executor, server.URL+"/items?page=0", LinkHeaderStrategy()))
+
+	// then
+	if !reflect.DeepEqual(bodies, []string{"page 0", "page 1", "page 2"}) {
+		t.Errorf("unexpected pages %v", bodies)
+	}
+}
+
+// Tests that Paginate follows cursors in the json body.
+func Test_Paginate_JSONCursor(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			w.Write([]byte(`{"meta":{"next":"abc"}}`))
+		case "abc":
+			w.Write([]byte(`{"meta":{"next":null}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	executor := NewExecutor()
+	defer executor.Close()
+
+	// when
+	bodies := collectPages(t, Paginate(context.Background(), executor, server.URL, JSONCursorStrategy("meta.next", "cursor")))
+
+	// then
+	if len(bodies) != 2 {
+		t.Errorf("expected 2 pages, got %v", bodies)
+	}
+}
+
+// Tests that Paginate prefetches all pages, if the page count is known.
+func Test_Paginate_OffsetCount(t *testing.T) {
+	// given
+	requests := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Query().Get("offset")
+		fmt.Fprintf(w, `{"total":3,"offset":%s}`, r.URL.Query().Get("offset"))
+	}))
+	defer server.Close()
+
+	executor := NewExecutor()
+	defer executor.Close()
+
+	strategy := OffsetStrategy{
+		Param: "offset",
+		Step:  50,
+		Count: func(first Page) (int, error) {
+			var body struct {
+				Total int `json:"total"`
+			}
+			err := first.UnmarshalResponse(&body)
+			return body.Total, err
+		},
+	}
+
+	// when
+	bodies := collectPages(t, Paginate(context.Background(), executor, server.URL+"?offset=0", strategy))
+
+	// then
+	expected := []string{`{"total":3,"offset":0}`, `{"total":3,"offset":50}`, `{"total":3,"offset":100}`}
+	if !reflect.DeepEqual(bodies, expected) {
+		t.Errorf("unexpected pages %v", bodies)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("expected 3 requests, got %d", len(requests))
+	}
+}
+
+// Tests that Paginate stops at the first failing page.
+func Test_Paginate_Error(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "1" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	executor := NewExecutor()
+	defer executor.Close()
+
+	strategy := OffsetStrategy{
+		Param:  "page",
+		Start:  0,
+		Step:   1,
+		IsLast: func(Page) (bool, error) { return false, nil },
+	}
+
+	// when
+	var pages []Page
+	for page := range Paginate(context.Background(), executor, server.URL+"?page=0", strategy) {
+		pages = append(pages, page)
+	}
+
+	// then
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(pages))
+	}
+
+	if pages[1].Err() == nil {
+		t.Error("expected error for second page, but none occurred")
+	}
+}
+
+// trackingTransport keeps track of the response bodies, which were not closed yet.
+type trackingTransport struct {
+	open int32
+}
+
+func (tr *trackingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	atomic.AddInt32(&tr.open, 1)
+	res.Body = &trackedBody{ReadCloser: res.Body, open: &tr.open}
+	return res, nil
+}
+
+type trackedBody struct {
+	io.ReadCloser
+
+	open   *int32
+	closed int32
+}
+
+func (b *trackedBody) Close() error {
+	if atomic.CompareAndSwapInt32(&b.closed, 0, 1) {
+		atomic.AddInt32(b.open, -1)
+	}
+
+	return b.ReadCloser.Close()
+}
+
+// Tests that Paginate cancels pending prefetches, and closes prefetched bodies, if a middle page fails.
+func Test_Paginate_PrefetchError(t *testing.T) {
+	// given
+	cancelled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "3":
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+			case <-time.After(5 * time.Second):
+			}
+		}
+		fmt.Fprintf(w, "page %s", r.URL.Query().Get("page"))
+	}))
+	defer server.Close()
+
+	transport := &trackingTransport{}
+	executor := NewExecutor(Client(&http.Client{Transport: transport}))
+	defer executor.Close()
+
+	strategy := OffsetStrategy{
+		Param: "page",
+		Step:  1,
+		Count: func(Page) (int, error) { return 4, nil },
+	}
+
+	// when
+	var pages []Page
+	for page := range Paginate(context.Background(), executor, server.URL+"?page=0", strategy) {
+		pages = append(pages, page)
+	}
+
+	// then
+	if len(pages) != 2 || pages[1].Err() == nil {
+		t.Fatalf("expected 2 pages with the second one failing, got %d", len(pages))
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected pending prefetch to be cancelled")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&transport.open) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected all bodies to be closed, %d still open", atomic.LoadInt32(&transport.open))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,251 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBodyIdleTimeout      = errors.New("body read idle timeout")
+	ErrBodyThroughputTooLow = errors.New("body read throughput too low")
+)
+
+// ReadLimits guard reading response bodies against trickling servers. Only the time
+// spent within Read calls is considered - so consumers reading a body late, or slowly,
+// are not penalized. If a limit is violated, the request is cancelled, and the Read
+// fails with an error wrapping ErrBodyIdleTimeout or ErrBodyThroughputTooLow.
+type ReadLimits struct {
+	// IdleTimeout is the maximum duration of a single Read call without any data.
+	IdleTimeout time.Duration
+
+	// MinThroughput is the minimum average throughput, in bytes per second.
+	MinThroughput float64
+
+	// ThroughputGrace is the time spent reading, before MinThroughput is enforced.
+	// If not set, one second is used.
+	ThroughputGrace time.Duration
+}
+
+func (l ReadLimits) enabled() bool {
+	return l.IdleTimeout > 0 || l.MinThroughput > 0
+}
+
+// override returns the limits, with all fields overridden which are set in other.
+func (l ReadLimits) override(other ReadLimits) ReadLimits {
+	if other.IdleTimeout > 0 {
+		l.IdleTimeout = other.IdleTimeout
+	}
+
+	if other.MinThroughput > 0 {
+		l.MinThroughput = other.MinThroughput
+	}
+
+	if other.ThroughputGrace > 0 {
+		l.ThroughputGrace = other.ThroughputGrace
+	}
+
+	return l
+}
+
+type readLimitsKey struct{}
+
+// WithReadLimits returns a copy of the context, which carries the given read limits.
+// All requests issued with it (or any context derived from it) use these limits,
+// overriding the ones of the Executor (see BodyReadLimits) for all fields set.
+func WithReadLimits(ctx context.Context, limits ReadLimits) context.Context {
+	return context.WithValue(ctx, readLimitsKey{}, limits)
+}
+
+func readLimitsFromContext(ctx context.Context) ReadLimits {
+	limits, _ := ctx.Value(readLimitsKey{}).(ReadLimits)
+	return limits
+}
+
+// sendGuarded sends the request like send, while guarding it via the
+// watchdog and the read limits - if configured.
+func (e Executor) sendGuarded(req *http.Request) (*http.Response, bool, error) {
+	limits := e.readLimits.override(readLimitsFromContext(req.Context()))
+
+	// cancelling the context is the only way to interrupt a blocked body read
+	var cancel context.CancelFunc
+	if limits.enabled() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(req.Context())
+		req = req.WithContext(ctx)
+	}
+
+	var watched *watchedRequest
+	if e.watchdog != nil {
+		req, watched = e.watchdog.watch(req)
+	}
+
+	// the body is guarded before anyone reads it - including the validator store
+	return e.send(req, func(req *http.Request) (*http.Response, error) {
+		res, err := e.client.Do(req)
+
+		if watched != nil {
+			res, err = e.watchdog.handle(watched, res, err)
+		}
+
+		if cancel != nil {
+			if err != nil {
+				cancel()
+			} else {
+				res.Body = newLimitedBody(res.Body, req, limits, cancel)
+			}
+		}
+
+		return res, err
+	})
+}
+
+// limitedBody enforces ReadLimits on reading a response body.
+type limitedBody struct {
+	io.ReadCloser
+
+	method string
+	url    string
+	limits ReadLimits
+	cancel context.CancelFunc
+	now    func() time.Time
+
+	mutex     sync.Mutex
+	timer     *time.Timer
+	reading   bool
+	readStart time.Time
+	busy      time.Duration
+	bytes     int64
+	err       error
+}
+
+func newLimitedBody(body io.ReadCloser, req *http.Request, limits ReadLimits, cancel context.CancelFunc) *limitedBody {
+	if limits.ThroughputGrace <= 0 {
+		limits.ThroughputGrace = time.Second
+	}
+
+	return &limitedBody{
+		ReadCloser: body,
+		method:     req.Method,
+		url:        req.URL.String(),
+		limits:     limits,
+		cancel:     cancel,
+		now:        time.Now,
+	}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	b.mutex.Lock()
+	if b.err != nil {
+		b.mutex.Unlock()
+		return 0, b.err
+	}
+
+	b.reading = true
+	b.readStart = b.now()
+	b.arm(0)
+	b.mutex.Unlock()
+
+	n, err := b.ReadCloser.Read(p)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.timer.Stop()
+	b.reading = false
+	b.busy += b.now().Sub(b.readStart)
+	b.bytes += int64(n)
+
+	// a violation detected while reading takes precedence over the resulting read error
+	if b.err != nil {
+		return n, b.err
+	}
+
+	if err == nil && b.throughputTooLow(b.busy) {
+		b.fail(ErrBodyThroughputTooLow)
+		return n, b.err
+	}
+
+	if err == io.EOF {
+		b.cancel()
+	}
+
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	b.mutex.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.mutex.Unlock()
+
+	err := b.ReadCloser.Close()
+	b.cancel()
+
+	return err
+}
+
+// arm schedules the next check of the limits, while a Read is blocked. The mutex must be held.
+func (b *limitedBody) arm(elapsed time.Duration) {
+	delay := time.Duration(-1)
+	if b.limits.IdleTimeout > 0 {
+		delay = b.limits.IdleTimeout - elapsed
+	}
+
+	if b.limits.MinThroughput > 0 {
+		interval := b.limits.ThroughputGrace
+		if interval > time.Second {
+			interval = time.Second
+		}
+
+		if delay < 0 || interval < delay {
+			delay = interval
+		}
+	}
+
+	if b.timer == nil {
+		b.timer = time.AfterFunc(delay, b.check)
+	} else {
+		b.timer.Reset(delay)
+	}
+}
+
+// check is called by the timer, while a Read is blocked.
+func (b *limitedBody) check() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// the Read might have returned in the meantime
+	if !b.reading || b.err != nil {
+		return
+	}
+
+	elapsed := b.now().Sub(b.readStart)
+	switch {
+	case b.limits.IdleTimeout > 0 && elapsed >= b.limits.IdleTimeout:
+		b.fail(ErrBodyIdleTimeout)
+	case b.throughputTooLow(b.busy + elapsed):
+		b.fail(ErrBodyThroughputTooLow)
+	default:
+		b.arm(elapsed)
+	}
+}
+
+func (b *limitedBody) throughputTooLow(busy time.Duration) bool {
+	if b.limits.MinThroughput <= 0 || busy < b.limits.ThroughputGrace {
+		return false
+	}
+
+	return float64(b.bytes)/busy.Seconds() < b.limits.MinThroughput
+}
+
+// fail records the violation, and cancels the request. The mutex must be held.
+func (b *limitedBody) fail(err error) {
+	b.err = fmt.Errorf("%s %s: %w", b.method, b.url, err)
+	b.cancel()
+}
@@ -0,0 +1,162 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTricklingServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stalled":
+			w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/trickle":
+			for i := 0; i < 100; i++ {
+				w.Write([]byte("a"))
+				w.(http.Flusher).Flush()
+
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		case "/pause":
+			w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+			time.Sleep(50 * time.Millisecond)
+			w.Write([]byte("b"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+}
+
+func readBody(t *testing.T, results []chan bulk.Result) (string, error) {
+	t.Helper()
+
+	result := <-results[0]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+	defer result.Res().Body.Close()
+
+	body, err := ioutil.ReadAll(result.Res().Body)
+	return string(body), err
+}
+
+// Tests that body reads without progress fail after the idle timeout.
+func Test_BodyReadLimits_IdleTimeout(t *testing.T) {
+	// given
+	server := newTricklingServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor(bulk.BodyReadLimits(bulk.ReadLimits{IdleTimeout: 20 * time.Millisecond}))
+	defer executor.Close()
+
+	// when
+	body, err := readBody(t, executor.AddRequests(context.Background(), server.URL+"/stalled"))
+
+	// then
+	if !errors.Is(err, bulk.ErrBodyIdleTimeout) {
+		t.Errorf("expected idle timeout error, got %v", err)
+	}
+
+	if body != "a" {
+		t.Errorf("expected partial body, got %q", body)
+	}
+}
+
+// Tests that body reads below the minimum throughput fail, with limits set per request.
+func Test_BodyReadLimits_MinThroughput(t *testing.T) {
+	// given
+	server := newTricklingServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	ctx := bulk.WithReadLimits(context.Background(), bulk.ReadLimits{
+		MinThroughput:   1000,
+		ThroughputGrace: 50 * time.Millisecond,
+	})
+
+	// when
+	_, err := readBody(t, executor.AddRequests(ctx, server.URL+"/trickle"))
+
+	// then
+	if !errors.Is(err, bulk.ErrBodyThroughputTooLow) {
+		t.Errorf("expected throughput error, got %v", err)
+	}
+}
+
+// Tests that per request limits override the ones of the executor, and that reading late is not penalized.
+func Test_BodyReadLimits_Override(t *testing.T) {
+	// given
+	server := newTricklingServer()
+	defer server.Close()
+
+	executor := bulk.NewExecutor(bulk.BodyReadLimits(bulk.ReadLimits{IdleTimeout: 20 * time.Millisecond}))
+	defer executor.Close()
+
+	ctx := bulk.WithReadLimits(context.Background(), bulk.ReadLimits{IdleTimeout: time.Second})
+
+	// when
+	paused, pausedErr := readBody(t, executor.AddRequests(ctx, server.URL+"/pause"))
+
+	results := executor.AddRequests(context.Background(), server.URL+"/ok")
+	time.Sleep(50 * time.Millisecond)
+	late, lateErr := readBody(t, results)
+
+	// then
+	if pausedErr != nil || paused != "ab" {
+		t.Errorf("expected full body, got %q (%v)", paused, pausedErr)
+	}
+
+	if lateErr != nil || late != "ok" {
+		t.Errorf("expected full body, got %q (%v)", late, lateErr)
+	}
+}
+
+// Tests that body read limits also apply to bodies buffered for conditional requests.
+func Test_BodyReadLimits_ConditionalRequests(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("a"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor(
+		bulk.ConditionalRequests(bulk.NewMemoryValidatorStore()),
+		bulk.BodyReadLimits(bulk.ReadLimits{IdleTimeout: 20 * time.Millisecond}),
+	)
+	defer executor.Close()
+
+	// when
+	done := make(chan bulk.Result, 1)
+	go func() {
+		done <- <-executor.AddRequests(context.Background(), server.URL)[0]
+	}()
+
+	// then
+	select {
+	case result := <-done:
+		if !errors.Is(result.Err(), bulk.ErrBodyIdleTimeout) {
+			t.Errorf("expected idle timeout error, got %v", result.Err())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the stalled body to time out")
+	}
+}
@@ -0,0 +1,81 @@
+package bulk
+
+import (
+	"context"
+	"net/http"
+)
+
+// Requester covers the request submission methods of an Executor. Accepting a Requester
+// instead of an Executor allows substituting a fake (such as bulktest.FakeExecutor) in
+// tests, or decorating an Executor. All helpers of this package (e.g. FetchLastModified,
+// Gather, Paginate, ExecuteGraph and NewLoader) accept a Requester - batch spans (see
+// Tracing) are only recorded, if it is an Executor.
+type Requester interface {
+	AddRequests(ctx context.Context, urls ...string) []chan Result
+	AddRequestsWithInterceptor(ctx context.Context, modifyRequest func(r *http.Request) error, urls ...string) []chan Result
+
+	AddFutureRequests(ctx context.Context, urls ...string) []*Future
+	AddFutureRequestsWithInterceptor(ctx context.Context, modifyRequest func(r *http.Request) error, urls ...string) []*Future
+
+	AddKeyedRequests(ctx context.Context, urls map[string]string) map[string]chan Result
+	AddKeyedRequestsWithInterceptor(ctx context.Context, modifyRequest func(r *http.Request) error, urls map[string]string) map[string]chan Result
+
+	AddKeyedFutureRequests(ctx context.Context, urls map[string]string) map[string]*Future
+	AddKeyedFutureRequestsWithInterceptor(ctx context.Context, modifyRequest func(r *http.Request) error, urls map[string]string) map[string]*Future
+}
+
+var _ Requester = (*Executor)(nil)
+
+// NewResult creates a Result, as returned by a Requester. This is meant for implementing
+// fakes or decorators - the key is empty for requests not issued via keyed methods.
+func NewResult(key, url string, res *http.Response, err error) Result {
+	return Result{key: key, url: url, res: res, err: err}
+}
+
+// NewFuture wraps the given channel in a Future, as returned by a Requester. This is
+// meant for implementing fakes or decorators.
+func NewFuture(resultChan chan Result) *Future {
+	return &Future{resultChan: resultChan}
+}
+
+// asExecutor returns the Executor behind the requester, if it is one.
+func asExecutor(requester Requester) *Executor {
+	switch executor := requester.(type) {
+	case *Executor:
+		return executor
+	case Executor:
+		return &executor
+	}
+
+	return nil
+}
+
+// startRequesterBatch starts a batch span via startBatch, if the requester is an
+// Executor. Batches of other requesters are not traced.
+func startRequesterBatch(ctx context.Context, requester Requester, name string, size int) (context.Context, *batchSpan) {
+	if executor := asExecutor(requester); executor != nil {
+		return executor.startBatch(ctx, name, size)
+	}
+
+	return ctx, nil
+}
+
+// addRequest issues a single request via the requester, keyed by key if not empty.
+// Executors are called directly, so the request is not wrapped in another batch span.
+func addRequest(
+	ctx context.Context,
+	requester Requester,
+	modifyRequest func(r *http.Request) error,
+	key string,
+	url string,
+) chan Result {
+	if executor := asExecutor(requester); executor != nil {
+		return executor.addRequestInternal(ctx, modifyRequest, key, url)
+	}
+
+	if key != "" {
+		return requester.AddKeyedRequestsWithInterceptor(ctx, modifyRequest, map[string]string{key: url})[key]
+	}
+
+	return requester.AddRequestsWithInterceptor(ctx, modifyRequest, url)[0]
+}
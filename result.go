@@ -9,10 +9,20 @@ import (
 
 // Result is a simple data holder for bulk request results.
 type Result struct {
+	key string
 	url string
 	res *http.Response
 	dur time.Duration
 	err error
+
+	revalidated bool
+	cacheHit    bool
+}
+
+// Key returns the key the request was issued with via one of the keyed
+// Executor methods. For positional requests, this is always empty.
+func (r Result) Key() string {
+	return r.key
 }
 
 // URL returns the originally requested url. If you want to know the final URL, look at the HTTP response.
@@ -35,6 +45,18 @@ func (r Result) Duration() time.Duration {
 	return r.dur
 }
 
+// Revalidated returns true, if the server responded with 304 Not Modified to a
+// conditional request, and the response was served from the validator store.
+func (r Result) Revalidated() bool {
+	return r.revalidated
+}
+
+// CacheHit returns true, if the response was served from the cache (see the
+// Cache option) instead of requesting it from the server.
+func (r Result) CacheHit() bool {
+	return r.cacheHit
+}
+
 // UnmarshalResponse unmarshals the http response directly into the provided interface
 // type (remember to provide a reference, not a value!), and closes the stream afterwards.
 //
@@ -53,3 +75,8 @@ func (r Result) UnmarshalResponse(target interface{}) error {
 
 	return json.Unmarshal(body, target)
 }
+
+// failed reports whether the request resulted in an error or a 5xx status code.
+func (r Result) failed() bool {
+	return r.err != nil || (r.res != nil && r.res.StatusCode >= 500)
+}
@@ -44,12 +44,17 @@ func Test_Result_Getters(t *testing.T) {
 	}
 
 	result := Result{
+		key: "test-key",
 		url: "test-url",
 		res: testResponse,
 		dur: time.Hour,
 		err: testError,
 	}
 
+	if result.Key() != "test-key" {
+		t.Error("getter for key broken")
+	}
+
 	if result.URL() != "test-url" {
 		t.Error("getter for URL broken")
 	}
@@ -0,0 +1,123 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// flightGroup collapses identical in-flight requests into a single one.
+type flightGroup struct {
+	headers []string
+
+	mutex   sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done      chan struct{}
+	followers int
+
+	result Result
+	body   []byte
+}
+
+func newFlightGroup(headers []string) *flightGroup {
+	canonical := make([]string, len(headers))
+	for i, header := range headers {
+		canonical[i] = http.CanonicalHeaderKey(header)
+	}
+
+	return &flightGroup{headers: canonical, flights: map[string]*flight{}}
+}
+
+// accepts reports whether the request may be deduplicated at all.
+func (g *flightGroup) accepts(req *http.Request) bool {
+	return req.Method == http.MethodGet || req.Method == http.MethodHead
+}
+
+func (g *flightGroup) key(req *http.Request) string {
+	var builder strings.Builder
+	builder.WriteString(req.Method)
+	builder.WriteString(" ")
+	builder.WriteString(req.URL.String())
+
+	for _, header := range g.headers {
+		builder.WriteString("\n")
+		builder.WriteString(header)
+		builder.WriteString(": ")
+		builder.WriteString(strings.Join(req.Header.Values(header), ", "))
+	}
+
+	return builder.String()
+}
+
+// do executes fn, unless an execution for the same key is already in flight - in
+// which case its outcome is awaited instead. If the outcome is shared with others,
+// the body is buffered, and each caller receives its own copy.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() Result) Result {
+	g.mutex.Lock()
+	if f, ok := g.flights[key]; ok {
+		f.followers++
+		g.mutex.Unlock()
+
+		select {
+		case <-f.done:
+			return f.copy()
+		case <-ctx.Done():
+			return Result{err: ctx.Err()}
+		}
+	}
+
+	f := &flight{done: make(chan struct{})}
+	g.flights[key] = f
+	g.mutex.Unlock()
+
+	result := fn()
+
+	// from here on, no further followers can join
+	g.mutex.Lock()
+	delete(g.flights, key)
+	followers := f.followers
+	g.mutex.Unlock()
+
+	if followers == 0 {
+		f.result = result
+		close(f.done)
+		return result
+	}
+
+	if result.res != nil {
+		body, err := ioutil.ReadAll(result.res.Body)
+		result.res.Body.Close()
+
+		if err != nil {
+			result = Result{dur: result.dur, err: err}
+		} else {
+			f.body = body
+		}
+	}
+
+	f.result = result
+	close(f.done)
+
+	return f.copy()
+}
+
+// copy returns the outcome of the flight, with an independently readable body.
+func (f *flight) copy() Result {
+	result := f.result
+	if result.res == nil {
+		return result
+	}
+
+	res := *result.res
+	res.Header = res.Header.Clone()
+	res.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+	result.res = &res
+
+	return result
+}
@@ -0,0 +1,90 @@
+package bulk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func (g *flightGroup) waitForFollowers(t *testing.T, followers int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		g.mutex.Lock()
+		count := 0
+		for _, f := range g.flights {
+			count += f.followers
+		}
+		g.mutex.Unlock()
+
+		if count == followers {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("expected %d followers to join", followers)
+}
+
+// Tests that identical in-flight requests are collapsed, and each caller can read the body.
+func Test_Deduplicate(t *testing.T) {
+	// given
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	executor := NewExecutor(Deduplicate())
+	defer executor.Close()
+
+	// when
+	results := executor.AddRequests(context.Background(), server.URL, server.URL, server.URL, server.URL)
+	executor.flights.waitForFollowers(t, 3)
+	close(release)
+
+	// then
+	for i, resultChan := range results {
+		result := <-resultChan
+		if result.Err() != nil {
+			t.Fatalf("unexpected error %s", result.Err())
+		}
+
+		body, err := ioutil.ReadAll(result.Res().Body)
+		result.Res().Body.Close()
+		if err != nil || string(body) != "payload" {
+			t.Errorf("result %d: unexpected body %q (%v)", i, body, err)
+		}
+	}
+
+	if hits := atomic.LoadInt32(&hits); hits != 1 {
+		t.Errorf("expected 1 request to the server, got %d", hits)
+	}
+}
+
+// Tests that requests differing in a selected header are not collapsed.
+func Test_Deduplicate_Headers(t *testing.T) {
+	// given
+	group := newFlightGroup([]string{"accept-language"})
+
+	german, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	german.Header.Set("Accept-Language", "de")
+	english, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	english.Header.Set("Accept-Language", "en")
+	post, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+
+	// then
+	if group.key(german) == group.key(english) {
+		t.Error("requests with different selected headers share a key")
+	}
+
+	if !group.accepts(german) || group.accepts(post) {
+		t.Error("only GET and HEAD requests must be deduplicated")
+	}
+}
@@ -0,0 +1,212 @@
+package bulk
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RequestState is the state of a request, which is tracked by an Executor.
+type RequestState string
+
+const (
+	// RequestStateQueued is the state of a request, which waits for a slot of the concurrency limit.
+	RequestStateQueued RequestState = "queued"
+	// RequestStateInFlight is the state of a request, which was sent and awaits its response.
+	RequestStateInFlight RequestState = "in-flight"
+)
+
+// HostStats are the request counts of an Executor for a single host.
+type HostStats struct {
+	Queued    int `json:"queued"`
+	InFlight  int `json:"inFlight"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// Stats is a snapshot of the requests of an Executor. Only requests actually sent
+// upstream are counted - that is, cache hits and deduplicated requests are not.
+// A request is counted as failed, if it resulted in an error or a 5xx status code.
+type Stats struct {
+	HostStats
+
+	// OldestInFlight is the age of the oldest request currently in flight.
+	OldestInFlight time.Duration `json:"oldestInFlight"`
+
+	Hosts map[string]HostStats `json:"hosts"`
+}
+
+// RequestInfo describes a request, which is queued or in flight.
+type RequestInfo struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	State  RequestState `json:"state"`
+
+	// Age is the time since the request was queued.
+	Age time.Duration `json:"age"`
+}
+
+// Stats returns a snapshot of the request counts of the Executor.
+func (e Executor) Stats() Stats {
+	return e.tracker.stats()
+}
+
+// InFlight returns all requests, which are currently queued or in flight, oldest first.
+func (e Executor) InFlight() []RequestInfo {
+	return e.tracker.inFlight()
+}
+
+// DebugHandler returns a http.Handler, which responds with the Stats of the Executor, and
+// all of its queued or in-flight requests as JSON. It is meant to be mounted on a debug
+// endpoint, similar to expvar or pprof.
+func (e Executor) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(struct {
+			Stats    Stats         `json:"stats"`
+			Requests []RequestInfo `json:"requests"`
+		}{
+			Stats:    e.Stats(),
+			Requests: e.InFlight(),
+		})
+	})
+}
+
+type trackedRequest struct {
+	id     uint64
+	host   string
+	method string
+	url    string
+	state  RequestState
+	queued time.Time
+}
+
+// requestTracker keeps track of the requests of an Executor.
+type requestTracker struct {
+	mutex    sync.Mutex
+	nextID   uint64
+	requests map[uint64]*trackedRequest
+	hosts    map[string]*HostStats
+	now      func() time.Time
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		requests: map[uint64]*trackedRequest{},
+		hosts:    map[string]*HostStats{},
+		now:      time.Now,
+	}
+}
+
+func (t *requestTracker) queue(req *http.Request) *trackedRequest {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nextID++
+	tracked := &trackedRequest{
+		id:     t.nextID,
+		host:   req.URL.Host,
+		method: req.Method,
+		url:    req.URL.String(),
+		state:  RequestStateQueued,
+		queued: t.now(),
+	}
+	t.requests[tracked.id] = tracked
+
+	t.host(tracked.host).Queued++
+
+	return tracked
+}
+
+func (t *requestTracker) start(tracked *trackedRequest) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked.state = RequestStateInFlight
+
+	host := t.host(tracked.host)
+	host.Queued--
+	host.InFlight++
+}
+
+func (t *requestTracker) finish(tracked *trackedRequest, failed bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.requests, tracked.id)
+
+	host := t.host(tracked.host)
+	host.InFlight--
+	host.Completed++
+	if failed {
+		host.Failed++
+	}
+}
+
+// host returns the stats of the given host. The mutex must be held.
+func (t *requestTracker) host(host string) *HostStats {
+	stats, ok := t.hosts[host]
+	if !ok {
+		stats = &HostStats{}
+		t.hosts[host] = stats
+	}
+
+	return stats
+}
+
+func (t *requestTracker) stats() Stats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := Stats{Hosts: make(map[string]HostStats, len(t.hosts))}
+	for name, host := range t.hosts {
+		stats.Hosts[name] = *host
+
+		stats.Queued += host.Queued
+		stats.InFlight += host.InFlight
+		stats.Completed += host.Completed
+		stats.Failed += host.Failed
+	}
+
+	now := t.now()
+	for _, tracked := range t.requests {
+		if tracked.state == RequestStateInFlight && now.Sub(tracked.queued) > stats.OldestInFlight {
+			stats.OldestInFlight = now.Sub(tracked.queued)
+		}
+	}
+
+	return stats
+}
+
+func (t *requestTracker) inFlight() []RequestInfo {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	tracked := make([]*trackedRequest, 0, len(t.requests))
+	for _, request := range t.requests {
+		tracked = append(tracked, request)
+	}
+
+	// oldest first
+	sort.Slice(tracked, func(i, j int) bool {
+		return tracked[i].id < tracked[j].id
+	})
+
+	now := t.now()
+	requests := make([]RequestInfo, len(tracked))
+	for i, request := range tracked {
+		requests[i] = RequestInfo{
+			Method: request.method,
+			URL:    request.url,
+			State:  request.state,
+			Age:    now.Sub(request.queued),
+		}
+	}
+
+	return requests
+}
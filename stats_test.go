@@ -0,0 +1,123 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls the condition, until it is met or a second passed.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Tests that Stats and InFlight reflect queued, in-flight, completed and failed requests.
+func Test_Executor_Stats(t *testing.T) {
+	// given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor(bulk.ConcurrencyLimit(1))
+	defer executor.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	// when
+	first := executor.AddRequests(context.Background(), server.URL+"/broken")
+	waitFor(t, func() bool { return executor.Stats().InFlight == 1 })
+	second := executor.AddRequests(context.Background(), server.URL+"/ok")
+	waitFor(t, func() bool { return executor.Stats().Queued == 1 })
+
+	// then
+	stats := executor.Stats()
+	if stats.Hosts[host].InFlight != 1 || stats.Hosts[host].Queued != 1 || stats.OldestInFlight <= 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	requests := executor.InFlight()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	if requests[0].URL != server.URL+"/broken" || requests[0].State != bulk.RequestStateInFlight ||
+		requests[1].URL != server.URL+"/ok" || requests[1].State != bulk.RequestStateQueued {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+
+	// and when
+	close(release)
+	for _, resultChan := range append(first, second...) {
+		if result := <-resultChan; result.Err() == nil {
+			result.Res().Body.Close()
+		}
+	}
+
+	// then
+	stats = executor.Stats()
+	expected := bulk.HostStats{Completed: 2, Failed: 1}
+	if stats.HostStats != expected || stats.Hosts[host] != expected || stats.OldestInFlight != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if requests := executor.InFlight(); len(requests) != 0 {
+		t.Errorf("expected no requests, got %+v", requests)
+	}
+}
+
+// Tests that the debug handler lists the in-flight requests.
+func Test_Executor_DebugHandler(t *testing.T) {
+	// given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	executor := bulk.NewExecutor()
+	defer executor.Close()
+
+	results := executor.AddRequests(context.Background(), server.URL+"/pending")
+	waitFor(t, func() bool { return executor.Stats().InFlight == 1 })
+
+	// when
+	recorder := httptest.NewRecorder()
+	executor.DebugHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/debug/bulk", nil))
+
+	close(release)
+	if result := <-results[0]; result.Err() == nil {
+		result.Res().Body.Close()
+	}
+
+	// then
+	var response struct {
+		Stats    bulk.Stats         `json:"stats"`
+		Requests []bulk.RequestInfo `json:"requests"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	if response.Stats.InFlight != 1 || len(response.Requests) != 1 || response.Requests[0].URL != server.URL+"/pending" {
+		t.Errorf("unexpected response %+v", response)
+	}
+}
@@ -0,0 +1,248 @@
+package bulk
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	ErrInvalidTraceParent = errors.New("invalid traceparent")
+)
+
+// SpanStatusCode is the status of a Span.
+type SpanStatusCode int
+
+const (
+	SpanStatusUnset SpanStatusCode = iota
+	SpanStatusOK
+	SpanStatusError
+)
+
+// Tracer opens spans, in the shape of an OpenTelemetry tracer. A bridge to the
+// OpenTelemetry SDK (or any other tracing system) can be implemented in a few lines.
+//
+// The given context carries the parent span, as known to the tracer. In addition,
+// the SpanContext of the parent is available via SpanContextFromContext.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single span, in the shape of an OpenTelemetry span.
+type Span interface {
+	// SpanContext returns the identifiers of the span, which are propagated
+	// via the W3C traceparent and tracestate headers.
+	SpanContext() SpanContext
+	SetAttribute(key string, value interface{})
+	SetStatus(code SpanStatusCode, description string)
+	RecordError(err error)
+	End()
+}
+
+// SpanContext identifies a span, as defined by the W3C Trace Context specification.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	TraceFlags byte
+	TraceState string
+}
+
+// IsValid reports whether both trace and span id are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent returns the span context formatted as traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.TraceFlags})
+}
+
+// ParseTraceParent parses the given traceparent and tracestate header values.
+func ParseTraceParent(traceParent, traceState string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	sc := SpanContext{TraceState: strings.TrimSpace(traceState)}
+	var flags [1]byte
+	for _, field := range []struct {
+		value  string
+		target []byte
+	}{
+		{parts[1], sc.TraceID[:]},
+		{parts[2], sc.SpanID[:]},
+		{parts[3], flags[:]},
+	} {
+		if len(field.value) != 2*len(field.target) || strings.ToLower(field.value) != field.value {
+			return SpanContext{}, ErrInvalidTraceParent
+		}
+
+		if _, err := hex.Decode(field.target, []byte(field.value)); err != nil {
+			return SpanContext{}, ErrInvalidTraceParent
+		}
+	}
+	sc.TraceFlags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceParent
+	}
+
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpanContext returns a copy of the context, carrying the given span context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context carried by the context, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// ExtractSpanContext returns a copy of the context, carrying the span context of the
+// traceparent and tracestate headers (e.g. of an incoming request). If the headers are
+// missing or invalid, the context is returned as is.
+func ExtractSpanContext(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceParent(header.Get("traceparent"), header.Get("tracestate"))
+	if err != nil {
+		return ctx
+	}
+
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// startSpan opens a span, and makes its span context available via the returned context.
+func startSpan(tracer Tracer, ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := tracer.Start(ctx, name)
+	if sc := span.SpanContext(); sc.IsValid() {
+		ctx = ContextWithSpanContext(ctx, sc)
+	}
+
+	return ctx, span
+}
+
+// startRequestSpan opens the span of a single request, and injects
+// the traceparent and tracestate headers into the request.
+func (e Executor) startRequestSpan(ctx context.Context, req *http.Request) (context.Context, Span) {
+	ctx, span := startSpan(e.tracer, ctx, "HTTP "+req.Method)
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.full", req.URL.String())
+	span.SetAttribute("server.address", req.URL.Hostname())
+
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		req.Header.Set("traceparent", sc.TraceParent())
+		if sc.TraceState != "" {
+			req.Header.Set("tracestate", sc.TraceState)
+		} else {
+			req.Header.Del("tracestate")
+		}
+	}
+
+	return ctx, span
+}
+
+// endRequestSpan records the outcome of the request, and ends its span. Following
+// OpenTelemetry conventions for client spans, 4xx and 5xx responses are errors.
+func endRequestSpan(span Span, result Result) {
+	if result.cacheHit {
+		span.SetAttribute("http.cache_hit", true)
+	}
+
+	switch {
+	case result.err != nil:
+		span.RecordError(result.err)
+		span.SetStatus(SpanStatusError, result.err.Error())
+	case result.res != nil:
+		span.SetAttribute("http.response.status_code", result.res.StatusCode)
+		if result.res.StatusCode >= 400 {
+			span.SetStatus(SpanStatusError, http.StatusText(result.res.StatusCode))
+		}
+	}
+
+	span.End()
+}
+
+// batchSpan is the span of a batch of requests. A nil batchSpan (that is,
+// with tracing disabled) is valid, and does nothing.
+type batchSpan struct {
+	span      Span
+	remaining int32
+	failed    int32
+}
+
+// startBatch opens a span for a batch of the given size, if tracing is enabled.
+// The span either ends once finish was called for each request, or via end.
+func (e Executor) startBatch(ctx context.Context, name string, size int) (context.Context, *batchSpan) {
+	if e.tracer == nil {
+		return ctx, nil
+	}
+
+	ctx, span := startSpan(e.tracer, ctx, name)
+	span.SetAttribute("batch.size", size)
+
+	batch := &batchSpan{span: span, remaining: int32(size)}
+	if size == 0 {
+		batch.end(nil)
+	}
+
+	return ctx, batch
+}
+
+// observe returns a channel, which forwards the result of the given one,
+// after finishing the request within the batch.
+func (b *batchSpan) observe(resultChan chan Result) chan Result {
+	if b == nil {
+		return resultChan
+	}
+
+	observed := make(chan Result, 1)
+	go func() {
+		result := <-resultChan
+		b.finish(result)
+		observed <- result
+	}()
+
+	return observed
+}
+
+// finish marks a single request of the batch as finished, and ends
+// the span once all requests finished.
+func (b *batchSpan) finish(result Result) {
+	if b == nil {
+		return
+	}
+
+	if result.err != nil || (result.res != nil && result.res.StatusCode >= 400) {
+		atomic.AddInt32(&b.failed, 1)
+	}
+
+	if atomic.AddInt32(&b.remaining, -1) == 0 {
+		failed := atomic.LoadInt32(&b.failed)
+		b.span.SetAttribute("batch.failed", int(failed))
+		if failed > 0 {
+			b.span.SetStatus(SpanStatusError, "batch contains failed requests")
+		}
+		b.span.End()
+	}
+}
+
+// end records the error of the batch as a whole (if any), and ends the span.
+func (b *batchSpan) end(err error) {
+	if b == nil {
+		return
+	}
+
+	if err != nil {
+		b.span.RecordError(err)
+		b.span.SetStatus(SpanStatusError, err.Error())
+	}
+	b.span.End()
+}
@@ -0,0 +1,249 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type testSpan struct {
+	name    string
+	context bulk.SpanContext
+	parent  bulk.SpanContext
+
+	mutex      sync.Mutex
+	attributes map[string]interface{}
+	status     bulk.SpanStatusCode
+	err        error
+	ended      bool
+}
+
+func (s *testSpan) SpanContext() bulk.SpanContext {
+	return s.context
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.attributes[key] = value
+}
+
+func (s *testSpan) SetStatus(code bulk.SpanStatusCode, description string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status = code
+}
+
+func (s *testSpan) RecordError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
+
+func (s *testSpan) End() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ended = true
+}
+
+// testTracer derives parents via bulk.SpanContextFromContext, and hands out sequential span ids.
+type testTracer struct {
+	mutex sync.Mutex
+	spans []*testSpan
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, bulk.Span) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	parent := bulk.SpanContextFromContext(ctx)
+	span := &testSpan{name: name, parent: parent, attributes: map[string]interface{}{}}
+	span.context = bulk.SpanContext{TraceID: parent.TraceID, TraceState: parent.TraceState, TraceFlags: parent.TraceFlags}
+	span.context.SpanID[7] = byte(len(t.spans) + 1)
+	t.spans = append(t.spans, span)
+
+	return ctx, span
+}
+
+func (t *testTracer) byName(name string) []*testSpan {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var spans []*testSpan
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+// Tests that spans are opened per batch and request, and that the trace context is propagated.
+func Test_Tracing(t *testing.T) {
+	// given
+	var (
+		mutex        sync.Mutex
+		traceParents = map[string]string{}
+		traceStates  = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		traceParents[r.URL.Path] = r.Header.Get("traceparent")
+		traceStates[r.URL.Path] = r.Header.Get("tracestate")
+		mutex.Unlock()
+
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracer := &testTracer{}
+	executor := bulk.NewExecutor(bulk.Tracing(tracer))
+	defer executor.Close()
+
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	incoming.Set("tracestate", "vendor=value")
+	ctx := bulk.ExtractSpanContext(context.Background(), incoming)
+
+	// when
+	for _, resultChan := range executor.AddRequests(ctx, server.URL+"/ok", server.URL+"/missing") {
+		if result := <-resultChan; result.Err() == nil {
+			result.Res().Body.Close()
+		}
+	}
+
+	// then
+	batches := tracer.byName("bulk batch")
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch span, got %d", len(batches))
+	}
+
+	batch := batches[0]
+	if batch.parent.TraceParent() != incoming.Get("traceparent") {
+		t.Errorf("expected batch span to be a child of the incoming request, got parent %s", batch.parent.TraceParent())
+	}
+
+	if !batch.ended || batch.status != bulk.SpanStatusError || batch.attributes["batch.size"] != 2 {
+		t.Errorf("unexpected batch span %+v", batch)
+	}
+
+	requests := tracer.byName("HTTP GET")
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 request spans, got %d", len(requests))
+	}
+
+	for _, span := range requests {
+		if span.parent.SpanID != batch.context.SpanID {
+			t.Errorf("expected request span to be a child of the batch span")
+		}
+
+		if !span.ended {
+			t.Errorf("expected request span to be ended")
+		}
+
+		path := span.attributes["url.full"].(string)[len(server.URL):]
+		if traceParents[path] != span.context.TraceParent() || traceStates[path] != "vendor=value" {
+			t.Errorf("%s: unexpected trace headers %q and %q", path, traceParents[path], traceStates[path])
+		}
+
+		expectedStatus := bulk.SpanStatusUnset
+		if path == "/missing" {
+			expectedStatus = bulk.SpanStatusError
+		}
+
+		if span.status != expectedStatus {
+			t.Errorf("%s: expected status %d, got %d", path, expectedStatus, span.status)
+		}
+	}
+}
+
+// Tests that batch spans of futures are finished without resolving the futures of the caller.
+func Test_Tracing_Futures(t *testing.T) {
+	// given
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	tracer := &testTracer{}
+	executor := bulk.NewExecutor(bulk.Tracing(tracer))
+	defer executor.Close()
+
+	ctx := bulk.WithMemo(context.Background())
+
+	// when
+	futures := executor.AddFutureRequests(context.Background(), server.URL+"/plain")
+	futures = append(futures, executor.AddFutureRequests(ctx, server.URL+"/memo")...)
+	futures = append(futures, executor.AddFutureRequests(ctx, server.URL+"/memo")...)
+	close(release)
+
+	// then
+	batches := tracer.byName("bulk batch")
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 batch spans, got %d", len(batches))
+	}
+
+	for _, batch := range batches {
+		waitFor(t, func() bool {
+			batch.mutex.Lock()
+			defer batch.mutex.Unlock()
+
+			return batch.ended
+		})
+	}
+
+	for _, future := range futures {
+		if future.Done() {
+			t.Error("expected future not to be resolved without calling Get")
+		}
+	}
+
+	if err := bulk.WaitAll(futures...); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+// Tests that traceparent header values are parsed as defined by the W3C Trace Context specification.
+func Test_ParseTraceParent(t *testing.T) {
+	tests := []struct {
+		traceParent string
+		valid       bool
+	}{
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", true},
+		{"01-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-future", true},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra", false},
+		{"00-0AF7651916CD43DD8448EB211C80319C-b7ad6b7169203331-01", false},
+		{"00-00000000000000000000000000000000-b7ad6b7169203331-01", false},
+		{"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01", false},
+		{"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", false},
+		{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b71692033-01", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		// when
+		sc, err := bulk.ParseTraceParent(test.traceParent, "")
+
+		// then
+		if test.valid && (err != nil || sc.TraceID[0] != 0x0a || sc.SpanID[7] != 0x31 || sc.TraceFlags != 1) {
+			t.Errorf("%q: expected valid span context, got %+v (%v)", test.traceParent, sc, err)
+		}
+
+		if !test.valid && !errors.Is(err, bulk.ErrInvalidTraceParent) {
+			t.Errorf("%q: expected invalid traceparent error, got %v", test.traceParent, err)
+		}
+	}
+}
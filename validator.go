@@ -0,0 +1,155 @@
+package bulk
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// ValidatedResponse is a response stored alongside its validators,
+// for serving it again after a successful revalidation.
+type ValidatedResponse struct {
+	ETag         string
+	LastModified string
+
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// ValidatorStore remembers the validators (and the response) per url, for usage
+// with ConditionalRequests. Implementations must be safe for concurrent use.
+type ValidatorStore interface {
+	Load(url string) (*ValidatedResponse, bool)
+	Store(url string, response *ValidatedResponse)
+}
+
+// MemoryValidatorStore is a simple, unbounded in-memory ValidatorStore.
+type MemoryValidatorStore struct {
+	mutex   sync.RWMutex
+	entries map[string]*ValidatedResponse
+}
+
+// NewMemoryValidatorStore instantiates a new MemoryValidatorStore.
+func NewMemoryValidatorStore() *MemoryValidatorStore {
+	return &MemoryValidatorStore{entries: map[string]*ValidatedResponse{}}
+}
+
+// Load returns the stored response for the given url, if any.
+func (store *MemoryValidatorStore) Load(url string) (*ValidatedResponse, bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+
+	response, ok := store.entries[url]
+	return response, ok
+}
+
+// Store stores the response for the given url, replacing any previous one.
+func (store *MemoryValidatorStore) Store(url string, response *ValidatedResponse) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.entries[url] = response
+}
+
+// applyValidators adds the conditional headers for the stored response (if any)
+// to the request. Headers already set (e.g. by an interceptor) are left untouched.
+func applyValidators(store ValidatorStore, req *http.Request) *ValidatedResponse {
+	stored, ok := store.Load(req.URL.String())
+	if !ok {
+		return nil
+	}
+
+	if stored.ETag != "" && req.Header.Get("If-None-Match") == "" {
+		req.Header.Set("If-None-Match", stored.ETag)
+	}
+
+	if stored.LastModified != "" && req.Header.Get("If-Modified-Since") == "" {
+		req.Header.Set("If-Modified-Since", stored.LastModified)
+	}
+
+	return stored
+}
+
+// handleValidators replaces a 304 response with the stored one, or stores a
+// 200 response carrying validators.
+func handleValidators(
+	store ValidatorStore,
+	req *http.Request,
+	res *http.Response,
+	stored *ValidatedResponse,
+) (*http.Response, bool, error) {
+	switch {
+	case res.StatusCode == http.StatusNotModified && stored != nil:
+		io.Copy(ioutil.Discard, res.Body)
+		res.Body.Close()
+
+		// the 304 response carries updated metadata for the stored response
+		header := stored.Header.Clone()
+		for name, values := range res.Header {
+			header[name] = values
+		}
+
+		updated := &ValidatedResponse{
+			ETag:         stored.ETag,
+			LastModified: stored.LastModified,
+			StatusCode:   stored.StatusCode,
+			Header:       header,
+			Body:         stored.Body,
+		}
+		if etag := res.Header.Get("ETag"); etag != "" {
+			updated.ETag = etag
+		}
+		if lastModified := res.Header.Get("Last-Modified"); lastModified != "" {
+			updated.LastModified = lastModified
+		}
+		store.Store(req.URL.String(), updated)
+
+		return storedResponse(req, res, updated), true, nil
+	case res.StatusCode == http.StatusOK:
+		etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+		if etag == "" && lastModified == "" {
+			return res, false, nil
+		}
+
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return nil, false, err
+		}
+
+		store.Store(req.URL.String(), &ValidatedResponse{
+			ETag:         etag,
+			LastModified: lastModified,
+			StatusCode:   res.StatusCode,
+			Header:       res.Header.Clone(),
+			Body:         body,
+		})
+
+		res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return res, false, nil
+	default:
+		return res, false, nil
+	}
+}
+
+func storedResponse(req *http.Request, res *http.Response, stored *ValidatedResponse) *http.Response {
+	header := stored.Header.Clone()
+	header.Set("Content-Length", strconv.Itoa(len(stored.Body)))
+
+	return &http.Response{
+		Status:        strconv.Itoa(stored.StatusCode) + " " + http.StatusText(stored.StatusCode),
+		StatusCode:    stored.StatusCode,
+		Proto:         res.Proto,
+		ProtoMajor:    res.ProtoMajor,
+		ProtoMinor:    res.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(stored.Body)),
+		ContentLength: int64(len(stored.Body)),
+		Request:       req,
+		TLS:           res.TLS,
+	}
+}
@@ -0,0 +1,63 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that conditional requests are issued, and 304 responses are served from the store.
+func Test_ConditionalRequests(t *testing.T) {
+	// given
+	var conditionalRequests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	store := bulk.NewMemoryValidatorStore()
+	executor := bulk.NewExecutor(bulk.ConditionalRequests(store))
+	defer executor.Close()
+
+	for i, expectRevalidated := range []bool{false, true, true} {
+		// when
+		result := <-executor.AddRequests(context.Background(), server.URL)[0]
+		if result.Err() != nil {
+			t.Fatalf("unexpected error %s", result.Err())
+		}
+
+		// then
+		if result.Revalidated() != expectRevalidated {
+			t.Errorf("request %d: expected revalidated %t", i, expectRevalidated)
+		}
+
+		if result.Res().StatusCode != http.StatusOK {
+			t.Errorf("request %d: expected status 200, got %d", i, result.Res().StatusCode)
+		}
+
+		body, err := ioutil.ReadAll(result.Res().Body)
+		result.Res().Body.Close()
+		if err != nil || string(body) != "payload" {
+			t.Errorf("request %d: unexpected body %q (%v)", i, body, err)
+		}
+	}
+
+	if conditionalRequests != 2 {
+		t.Errorf("expected 2 conditional requests, got %d", conditionalRequests)
+	}
+
+	if stored, ok := store.Load(server.URL); !ok || stored.ETag != `"v1"` {
+		t.Errorf("validators not stored, got %+v", stored)
+	}
+}
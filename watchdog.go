@@ -0,0 +1,317 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWatchdogTimeout = errors.New("request cancelled by watchdog")
+)
+
+// RequestStateReadingBody is the state of a request, whose response body is being read.
+// This state is only reported by the watchdog (see Watchdog).
+const RequestStateReadingBody RequestState = "reading-body"
+
+// StuckRequest describes a request, which was detected by the watchdog.
+type StuckRequest struct {
+	RequestInfo
+
+	// Idle is the time the current Read of the response body is blocked without progress.
+	// It is only set for requests in the RequestStateReadingBody state.
+	Idle time.Duration
+}
+
+// WatchdogOptions is the option-wrapper for defining the workings of the Watchdog option.
+type WatchdogOptions struct {
+	Threshold       time.Duration
+	BodyIdleTimeout time.Duration
+	Interval        time.Duration
+	Cancel          bool
+	OnStuck         func(request StuckRequest)
+}
+
+type WatchdogOption func(*WatchdogOptions)
+
+// WatchdogBodyIdleTimeout sets the duration, after which reading a response body
+// without any progress is considered stalled. Only the time a Read is blocked counts,
+// so bodies not read yet (or consumers pausing between reads) are never considered
+// stalled. Per default (or with 0), stalled body reads are not detected.
+func WatchdogBodyIdleTimeout(timeout time.Duration) WatchdogOption {
+	return func(args *WatchdogOptions) {
+		args.BodyIdleTimeout = timeout
+	}
+}
+
+// WatchdogInterval sets the interval, in which requests are scanned. Per default,
+// half of the smaller one of threshold and body idle timeout is used.
+func WatchdogInterval(interval time.Duration) WatchdogOption {
+	return func(args *WatchdogOptions) {
+		args.Interval = interval
+	}
+}
+
+// WatchdogCancel regulates whether stuck requests (and stalled body reads) are
+// forcefully cancelled. The Result (or the body read) then fails with an error
+// wrapping ErrWatchdogTimeout. Per default, requests are not cancelled.
+func WatchdogCancel(cancel bool) WatchdogOption {
+	return func(args *WatchdogOptions) {
+		args.Cancel = cancel
+	}
+}
+
+// WatchdogCallback sets a callback, which is called once for each stuck request
+// (or stalled body read). Per default, no callback is used.
+func WatchdogCallback(onStuck func(request StuckRequest)) WatchdogOption {
+	return func(args *WatchdogOptions) {
+		args.OnStuck = onStuck
+	}
+}
+
+type watchedRequest struct {
+	id     uint64
+	method string
+	url    string
+	cancel context.CancelFunc
+
+	// guarded by the mutex of the watchdog
+	started   time.Time
+	lastRead  time.Time
+	responded bool
+	reading   bool
+	reported  bool
+	timedOut  bool
+}
+
+// watchdog periodically scans the watched requests, for detecting stuck requests.
+type watchdog struct {
+	args WatchdogOptions
+
+	mutex    sync.Mutex
+	nextID   uint64
+	requests map[uint64]*watchedRequest
+	now      func() time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newWatchdog(args WatchdogOptions) *watchdog {
+	w := &watchdog{
+		args:     args,
+		requests: map[uint64]*watchedRequest{},
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+
+	interval := args.Interval
+	if interval <= 0 {
+		interval = args.Threshold
+		if args.BodyIdleTimeout > 0 && (interval <= 0 || args.BodyIdleTimeout < interval) {
+			interval = args.BodyIdleTimeout
+		}
+		interval /= 2
+	}
+
+	if interval > 0 {
+		go w.run(interval)
+	}
+
+	return w
+}
+
+func (w *watchdog) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.scan()
+		}
+	}
+}
+
+func (w *watchdog) close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+// scan reports (and possibly cancels) all requests, which are stuck for the first time.
+// Callbacks are called before cancelling, so they observe the stuck request first.
+func (w *watchdog) scan() {
+	w.mutex.Lock()
+
+	var (
+		stuck   []StuckRequest
+		cancels []context.CancelFunc
+	)
+	now := w.now()
+	for _, watched := range w.requests {
+		// a body is only stalled while a Read is blocked - the consumer might just be busy otherwise
+		if watched.reported || (watched.responded && !watched.reading) {
+			continue
+		}
+
+		request := StuckRequest{RequestInfo: RequestInfo{
+			Method: watched.method,
+			URL:    watched.url,
+			State:  RequestStateInFlight,
+			Age:    now.Sub(watched.started),
+		}}
+
+		if watched.reading {
+			request.State = RequestStateReadingBody
+			request.Idle = now.Sub(watched.lastRead)
+
+			if w.args.BodyIdleTimeout <= 0 || request.Idle <= w.args.BodyIdleTimeout {
+				continue
+			}
+		} else if w.args.Threshold <= 0 || request.Age <= w.args.Threshold {
+			continue
+		}
+
+		watched.reported = true
+		stuck = append(stuck, request)
+
+		if w.args.Cancel {
+			watched.timedOut = true
+			cancels = append(cancels, watched.cancel)
+		}
+	}
+
+	w.mutex.Unlock()
+
+	if w.args.OnStuck != nil {
+		for _, request := range stuck {
+			w.args.OnStuck(request)
+		}
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// watch registers the request, and returns a copy of it with a cancelable context.
+func (w *watchdog) watch(req *http.Request) (*http.Request, *watchedRequest) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.nextID++
+	watched := &watchedRequest{
+		id:      w.nextID,
+		method:  req.Method,
+		url:     req.URL.String(),
+		cancel:  cancel,
+		started: w.now(),
+	}
+	w.requests[watched.id] = watched
+
+	return req.WithContext(ctx), watched
+}
+
+// handle processes the outcome of a watched request. A successful response is
+// watched further while reading its body, if a body idle timeout is configured.
+func (w *watchdog) handle(watched *watchedRequest, res *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		if w.timedOut(watched) {
+			err = fmt.Errorf("%s %s: %w", watched.method, watched.url, ErrWatchdogTimeout)
+		}
+		w.done(watched)
+		return nil, err
+	}
+
+	w.mutex.Lock()
+	watched.responded = true
+	watched.reported = false
+	if w.args.BodyIdleTimeout <= 0 {
+		// the context must remain alive while the body is read, but is not watched anymore
+		delete(w.requests, watched.id)
+	}
+	w.mutex.Unlock()
+
+	res.Body = &watchedBody{ReadCloser: res.Body, watchdog: w, watched: watched}
+	return res, nil
+}
+
+func (w *watchdog) timedOut(watched *watchedRequest) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return watched.timedOut
+}
+
+// beginRead starts the idle clock of the body, as a Read is about to block.
+func (w *watchdog) beginRead(watched *watchedRequest) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	watched.reading = true
+	watched.lastRead = w.now()
+}
+
+// endRead stops the idle clock of the body, as a Read returned.
+func (w *watchdog) endRead(watched *watchedRequest) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	watched.reading = false
+}
+
+// done unregisters the request, and releases its context.
+func (w *watchdog) done(watched *watchedRequest) {
+	w.mutex.Lock()
+	delete(w.requests, watched.id)
+	w.mutex.Unlock()
+
+	watched.cancel()
+}
+
+// watchedBody tracks the progress of reading a response body.
+type watchedBody struct {
+	io.ReadCloser
+
+	watchdog *watchdog
+	watched  *watchedRequest
+	doneOnce sync.Once
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	b.watchdog.beginRead(b.watched)
+	n, err := b.ReadCloser.Read(p)
+	b.watchdog.endRead(b.watched)
+
+	if err != nil && err != io.EOF && b.watchdog.timedOut(b.watched) {
+		err = fmt.Errorf("%s %s: reading body: %w", b.watched.method, b.watched.url, ErrWatchdogTimeout)
+	}
+
+	if err == io.EOF {
+		b.done()
+	}
+
+	return n, err
+}
+
+func (b *watchedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+
+	return err
+}
+
+func (b *watchedBody) done() {
+	b.doneOnce.Do(func() {
+		b.watchdog.done(b.watched)
+	})
+}
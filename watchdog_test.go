@@ -0,0 +1,203 @@
+package bulk_test
+
+import (
+	bulk "github.com/kernle32dll/httpbulk-go"
+
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type stuckRecorder struct {
+	mutex    sync.Mutex
+	requests []bulk.StuckRequest
+}
+
+func (r *stuckRecorder) record(request bulk.StuckRequest) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.requests = append(r.requests, request)
+}
+
+func (r *stuckRecorder) get() []bulk.StuckRequest {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]bulk.StuckRequest(nil), r.requests...)
+}
+
+// Tests that stuck requests are reported, and cancelled if configured.
+func Test_Watchdog_StuckRequest(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stuck" {
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+
+	recorder := &stuckRecorder{}
+	executor := bulk.NewExecutor(bulk.Watchdog(20*time.Millisecond,
+		bulk.WatchdogCancel(true),
+		bulk.WatchdogCallback(recorder.record),
+	))
+	defer executor.Close()
+
+	// when
+	results := executor.AddRequests(context.Background(), server.URL+"/stuck", server.URL+"/ok")
+
+	// then
+	if err := (<-results[0]).Err(); !errors.Is(err, bulk.ErrWatchdogTimeout) {
+		t.Errorf("expected watchdog timeout error, got %v", err)
+	}
+
+	result := <-results[1]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+	result.Res().Body.Close()
+
+	stuck := recorder.get()
+	if len(stuck) != 1 || stuck[0].URL != server.URL+"/stuck" || stuck[0].State != bulk.RequestStateInFlight || stuck[0].Age < 20*time.Millisecond {
+		t.Errorf("unexpected stuck requests %+v", stuck)
+	}
+}
+
+// Tests that stalled body reads are reported, and cancelled if configured.
+func Test_Watchdog_StalledBody(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	recorder := &stuckRecorder{}
+	executor := bulk.NewExecutor(bulk.Watchdog(time.Minute,
+		bulk.WatchdogBodyIdleTimeout(20*time.Millisecond),
+		bulk.WatchdogCancel(true),
+		bulk.WatchdogCallback(recorder.record),
+	))
+	defer executor.Close()
+
+	// when
+	result := <-executor.AddRequests(context.Background(), server.URL)[0]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+
+	body, err := ioutil.ReadAll(result.Res().Body)
+	result.Res().Body.Close()
+
+	// then
+	if !errors.Is(err, bulk.ErrWatchdogTimeout) {
+		t.Errorf("expected watchdog timeout error, got %v", err)
+	}
+
+	if string(body) != "partial" {
+		t.Errorf("expected partial body, got %q", body)
+	}
+
+	stuck := recorder.get()
+	if len(stuck) != 1 || stuck[0].State != bulk.RequestStateReadingBody || stuck[0].Idle < 20*time.Millisecond {
+		t.Errorf("unexpected stuck requests %+v", stuck)
+	}
+}
+
+// Tests that bodies read late are neither reported nor cancelled, as the idle clock starts with the first read.
+func Test_Watchdog_LateBodyRead(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	recorder := &stuckRecorder{}
+	executor := bulk.NewExecutor(bulk.Watchdog(time.Minute,
+		bulk.WatchdogBodyIdleTimeout(20*time.Millisecond),
+		bulk.WatchdogCancel(true),
+		bulk.WatchdogCallback(recorder.record),
+	))
+	defer executor.Close()
+
+	// when
+	future := executor.AddFutureRequests(context.Background(), server.URL)[0]
+	time.Sleep(100 * time.Millisecond)
+
+	result := future.Get()
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+
+	body, err := ioutil.ReadAll(result.Res().Body)
+	result.Res().Body.Close()
+
+	// then
+	if err != nil || string(body) != "hello" {
+		t.Errorf("expected complete body, got %q and %v", body, err)
+	}
+
+	if stuck := recorder.get(); len(stuck) != 0 {
+		t.Errorf("expected no stuck requests, got %+v", stuck)
+	}
+}
+
+// Tests that consumers pausing between reads are neither reported nor cancelled, as only blocked reads count.
+func Test_Watchdog_SlowConsumer(t *testing.T) {
+	// given
+	payload := strings.Repeat("a", 8*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	recorder := &stuckRecorder{}
+	executor := bulk.NewExecutor(bulk.Watchdog(time.Hour,
+		bulk.WatchdogBodyIdleTimeout(20*time.Millisecond),
+		bulk.WatchdogInterval(5*time.Millisecond),
+		bulk.WatchdogCancel(true),
+		bulk.WatchdogCallback(recorder.record),
+	))
+	defer executor.Close()
+
+	// when
+	result := <-executor.AddRequests(context.Background(), server.URL)[0]
+	if result.Err() != nil {
+		t.Fatalf("unexpected error %s", result.Err())
+	}
+
+	var body []byte
+	buffer := make([]byte, 1024)
+	for {
+		n, err := result.Res().Body.Read(buffer)
+		body = append(body, buffer[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error after %d bytes: %s", len(body), err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+	result.Res().Body.Close()
+
+	// then
+	if string(body) != payload {
+		t.Errorf("expected complete body, got %d bytes", len(body))
+	}
+
+	if stuck := recorder.get(); len(stuck) != 0 {
+		t.Errorf("expected no stuck requests, got %+v", stuck)
+	}
+}